@@ -1,3 +1,5 @@
 module vs_export
 
 go 1.14
+
+require golang.org/x/text v0.3.8
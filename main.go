@@ -7,13 +7,30 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"vs_export/sln"
 )
 
 func main() {
-	path := flag.String("s", "", "sln or vcxproj file path")
+	path := flag.String("s", "", "sln, slnx, slnf, vcxproj or vcproj file path")
 	configuration := flag.String("c", "Debug|x64",
 		"Configuration, [configuration|platform], default Debug|x64")
+	noDirectoryBuild := flag.Bool("no-directory-build", false,
+		"do not import Directory.Build.props/Directory.Build.targets")
+	excluded := flag.String("excluded", "drop",
+		"how to handle files excluded from build, drop or mark (mark is not accepted by clangd/clang-tidy)")
+	shared := flag.String("shared", "all",
+		"how to handle files shared by several projects, all, first or prefer:<project>")
+	pch := flag.String("pch", "force-include",
+		"how to handle precompiled headers, force-include, msvc or strip")
+	noWarningFlags := flag.Bool("no-warning-flags", false,
+		"do not emit warning flags such as /W4, /WX and /wd")
+	driver := flag.String("driver", "clang-cl",
+		"compiler driver used in the generated commands, clang-cl, gnu or cl")
+	driverPath := flag.String("driver-path", "",
+		"compiler executable path, default clang-cl.exe, clang++ or cl.exe by driver")
+	graph := flag.Bool("graph", false,
+		"print the project dependency graph instead of compile_commands.json")
 	flag.Parse()
 
 	if *path == "" {
@@ -21,11 +38,51 @@ func main() {
 		os.Exit(1)
 	}
 
+	excludedPolicy, err := sln.ParseExcludedPolicy(*excluded)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	sharedPolicy, err := sln.ParseSharedPolicy(*shared)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	pchPolicy, err := sln.ParsePchPolicy(*pch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	driverKind, err := sln.ParseDriverKind(*driver)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	solution, err := sln.NewSln(*path)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	solution.SetOptions(sln.Options{
+		NoDirectoryBuild: *noDirectoryBuild,
+		Excluded:         excludedPolicy,
+		Shared:           sharedPolicy,
+		Pch:              pchPolicy,
+		NoWarningFlags:   *noWarningFlags,
+		Driver:           driverKind,
+		DriverPath:       *driverPath,
+	})
+	if *graph {
+		if err := printGraph(solution, *configuration); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	cmdList, err := solution.CompileCommandsJson(*configuration)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -40,13 +97,71 @@ func main() {
 	ioutil.WriteFile("compile_commands.json", js[:], 0644)
 }
 
+// printGraph 按生成顺序输出每个项目的直接依赖、全部依赖以及受其影响的项目
+func printGraph(solution sln.Sln, configuration string) error {
+	g, err := solution.DependencyGraph(configuration)
+	if err != nil {
+		return err
+	}
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		// 存在循环依赖时按解决方案中的顺序输出
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		order = g.Projects
+	}
+	for _, pro := range order {
+		fmt.Printf("%s (%s)\n", pro.Name(), pro.ProjectPath)
+		fmt.Printf("    dependencies:     %s\n", projectNames(g.Dependencies(pro)))
+		fmt.Printf("    all dependencies: %s\n", projectNames(g.TransitiveDependencies(pro)))
+		fmt.Printf("    dependents:       %s\n", projectNames(g.Dependents(pro)))
+		fmt.Printf("    affected:         %s\n", projectNames(g.TransitiveDependents(pro)))
+	}
+	return nil
+}
+
+func projectNames(list []*sln.Project) string {
+	var names []string
+	for _, pro := range list {
+		names = append(names, pro.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
 func usage() {
-	var echo = `Usage: %s -s <path> -c <configuration>
+	var echo = `Usage: %s -s <path> -c <configuration> [options]
 
 Where:
-            -s   path                        sln or vcxproj filename
+            -s   path                        sln, slnx, slnf, vcxproj or vcproj filename
             -c   configuration               project configuration,eg Debug|x64.
                                              default Debug|x64
+            -no-directory-build              do not import Directory.Build.props
+                                             and Directory.Build.targets
+            -excluded   drop|mark            files excluded from build are dropped,
+                                             or kept with "excluded": true.
+                                             clangd and clang-tidy reject the
+                                             extra field, use mark only for
+                                             custom tools. default drop
+            -shared   all|first|prefer:name  files shared by several projects (eg
+                                             .vcxitems) get an entry per project,
+                                             only the first project's entry, or
+                                             the entry of the project given by
+                                             name or project file path.
+                                             default all
+            -pch   force-include|msvc|strip  files using a precompiled header force
+                                             include its header, get cl.exe's
+                                             /Yc, /Yu and /Fp, or no pch flags.
+                                             default force-include
+            -no-warning-flags                do not emit WarningLevel, /WX, /wd
+                                             and other warning flags
+            -driver   clang-cl|gnu|cl        compiler driver syntax of the commands:
+                                             clang-cl, clang++/g++ style, or cl.exe.
+                                             MSVC-only options are translated or
+                                             dropped. default clang-cl
+            -driver-path   path              compiler executable in the commands.
+                                             default clang-cl.exe, clang++ or cl.exe
+            -graph                           print the project dependency graph
+                                             in build order instead of generating
+                                             compile_commands.json
 	`
 	echo = fmt.Sprintf(echo, filepath.Base(os.Args[0]))
 	fmt.Println(echo)
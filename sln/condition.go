@@ -0,0 +1,374 @@
+package sln
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MSBuild条件表达式的词法单元类型
+type condTokenKind int
+
+const (
+	condEOF      condTokenKind = iota
+	condString                 // '...'
+	condProperty               // $(...)、@(...)、%(...)
+	condWord                   // 未加引号的标识符或数字
+	condLParen
+	condRParen
+	condComma
+	condNot
+	condOp // == != < > <= >=
+)
+
+type condToken struct {
+	kind  condTokenKind
+	text  string
+	begin int
+}
+
+// ConditionContext 条件求值所需的上下文
+type ConditionContext struct {
+	// 属性集，用于展开条件中的$(...)引用
+	Properties *PropertySet
+	// Exists等函数中相对路径的基准目录，一般为条件所在文件的目录
+	BaseDir string
+}
+
+// EvaluateCondition 求值MSBuild的Condition表达式，空条件视为成立
+//
+// 支持==、!=、<、>、<=、>=、and、or、!、括号以及Exists()和HasTrailingSlash()函数，
+// 字符串比较不区分大小写，两边均为数字时按数值比较；and和or与MSBuild一样短路求值，
+// 被短路的一侧只检查语法。
+func EvaluateCondition(cond string, ctx ConditionContext) (bool, error) {
+	if strings.TrimSpace(cond) == "" {
+		return true, nil
+	}
+	tokens, err := tokenizeCondition(cond)
+	if err != nil {
+		return false, err
+	}
+	p := &condParser{src: cond, tokens: tokens, ctx: ctx}
+	result, err := p.parseOr()
+	if err != nil {
+		return false, err
+	}
+	if tok := p.peek(); tok.kind != condEOF {
+		return false, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return result, nil
+}
+
+func tokenizeCondition(s string) ([]condToken, error) {
+	var tokens []condToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, condToken{condLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, condToken{condRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, condToken{condComma, ",", i})
+			i++
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("condition %q: unterminated string at %d", s, i)
+			}
+			tokens = append(tokens, condToken{condString, s[i+1 : i+1+end], i})
+			i += end + 2
+		case (c == '$' || c == '@' || c == '%') && i+1 < len(s) && s[i+1] == '(':
+			end := matchingParen(s, i+1)
+			if end < 0 {
+				return nil, fmt.Errorf("condition %q: unbalanced parenthesis at %d", s, i)
+			}
+			tokens = append(tokens, condToken{condProperty, s[i : end+1], i})
+			i = end + 1
+		case c == '!' && i+1 < len(s) && s[i+1] == '=':
+			tokens = append(tokens, condToken{condOp, "!=", i})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, condToken{condNot, "!", i})
+			i++
+		case c == '=' && i+1 < len(s) && s[i+1] == '=':
+			tokens = append(tokens, condToken{condOp, "==", i})
+			i += 2
+		case c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(s) && s[i+1] == '=' {
+				op += "="
+			}
+			tokens = append(tokens, condToken{condOp, op, i})
+			i += len(op)
+		case isConditionWordChar(c):
+			start := i
+			for i < len(s) && isConditionWordChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, condToken{condWord, s[start:i], start})
+		default:
+			return nil, fmt.Errorf("condition %q: unexpected character %q at %d", s, c, i)
+		}
+	}
+	tokens = append(tokens, condToken{condEOF, "", len(s)})
+	return tokens, nil
+}
+
+func isConditionWordChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '+' ||
+		(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// condParser 递归下降解析并直接求值条件表达式
+type condParser struct {
+	src    string
+	tokens []condToken
+	pos    int
+	ctx    ConditionContext
+	// 大于0时表示正在解析被短路的子表达式，其中的类型错误不影响结果
+	skip int
+}
+
+func (p *condParser) peek() condToken {
+	return p.tokens[p.pos]
+}
+
+func (p *condParser) next() condToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != condEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *condParser) isKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == condWord && strings.EqualFold(tok.text, word)
+}
+
+func (p *condParser) errorf(tok condToken, format string, args ...interface{}) error {
+	return fmt.Errorf("condition %q: %s at %d", p.src, fmt.Sprintf(format, args...), tok.begin)
+}
+
+// or := and ('or' and)*
+func (p *condParser) parseOr() (bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return false, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseSkippable(left, p.parseAnd)
+		if err != nil {
+			return false, err
+		}
+		left = left || right
+	}
+	return left, nil
+}
+
+// and := unary ('and' unary)*
+func (p *condParser) parseAnd() (bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return false, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseSkippable(!left, p.parseUnary)
+		if err != nil {
+			return false, err
+		}
+		left = left && right
+	}
+	return left, nil
+}
+
+// parseSkippable 解析右侧的子表达式，skipped为true时结果已经确定，只检查语法
+func (p *condParser) parseSkippable(skipped bool, parse func() (bool, error)) (bool, error) {
+	if skipped {
+		p.skip++
+		defer func() { p.skip-- }()
+	}
+	return parse()
+}
+
+// valueError 返回求值时的类型错误，被短路的子表达式中忽略该错误
+func (p *condParser) valueError(tok condToken, format string, args ...interface{}) (bool, error) {
+	if p.skip > 0 {
+		return false, nil
+	}
+	return false, p.errorf(tok, format, args...)
+}
+
+// unary := '!' unary | '(' or ')' | comparison
+func (p *condParser) parseUnary() (bool, error) {
+	switch p.peek().kind {
+	case condNot:
+		p.next()
+		v, err := p.parseUnary()
+		return !v, err
+	case condLParen:
+		p.next()
+		v, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if tok := p.next(); tok.kind != condRParen {
+			return false, p.errorf(tok, "expected ')'")
+		}
+		return v, nil
+	}
+	return p.parseComparison()
+}
+
+// comparison := operand (op operand)?
+func (p *condParser) parseComparison() (bool, error) {
+	first := p.peek()
+	left, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+	if p.peek().kind != condOp {
+		b, ok := parseConditionBool(left)
+		if !ok {
+			return p.valueError(first, "%q is not a boolean value", left)
+		}
+		return b, nil
+	}
+	op := p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+	return p.compare(op, left, right)
+}
+
+func (p *condParser) compare(op condToken, left, right string) (bool, error) {
+	l, lok := parseConditionNumber(left)
+	r, rok := parseConditionNumber(right)
+	switch op.text {
+	case "==":
+		if lok && rok {
+			return l == r, nil
+		}
+		return strings.EqualFold(left, right), nil
+	case "!=":
+		if lok && rok {
+			return l != r, nil
+		}
+		return !strings.EqualFold(left, right), nil
+	}
+	if !lok || !rok {
+		return p.valueError(op, "operator %s requires numeric operands, got %q and %q", op.text, left, right)
+	}
+	switch op.text {
+	case "<":
+		return l < r, nil
+	case ">":
+		return l > r, nil
+	case "<=":
+		return l <= r, nil
+	default:
+		return l >= r, nil
+	}
+}
+
+// operand := string | property | word | word '(' args ')'
+func (p *condParser) parseOperand() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case condString, condProperty:
+		return p.ctx.Properties.Expand(tok.text), nil
+	case condWord:
+		if p.peek().kind == condLParen {
+			return p.parseFunction(tok)
+		}
+		return tok.text, nil
+	case condEOF:
+		return "", p.errorf(tok, "unexpected end of condition")
+	}
+	return "", p.errorf(tok, "unexpected %q", tok.text)
+}
+
+func (p *condParser) parseFunction(name condToken) (string, error) {
+	p.next() // '('
+	var args []string
+	if p.peek().kind != condRParen {
+		for {
+			arg, err := p.parseOperand()
+			if err != nil {
+				return "", err
+			}
+			args = append(args, arg)
+			if p.peek().kind != condComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if tok := p.next(); tok.kind != condRParen {
+		return "", p.errorf(tok, "expected ')'")
+	}
+
+	switch strings.ToLower(name.text) {
+	case "exists":
+		if len(args) != 1 {
+			return "", p.errorf(name, "Exists() takes 1 argument")
+		}
+		return strconv.FormatBool(p.exists(args[0])), nil
+	case "hastrailingslash":
+		if len(args) != 1 {
+			return "", p.errorf(name, "HasTrailingSlash() takes 1 argument")
+		}
+		s := args[0]
+		return strconv.FormatBool(strings.HasSuffix(s, "\\") || strings.HasSuffix(s, "/")), nil
+	}
+	return "", p.errorf(name, "unknown function %s()", name.text)
+}
+
+func (p *condParser) exists(path string) bool {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return false
+	}
+	path = nativePath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.ctx.BaseDir, path)
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// parseConditionBool 解析条件中的布尔值
+func parseConditionBool(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "on", "yes", "!false", "!off", "!no":
+		return true, true
+	case "false", "off", "no", "!true", "!on", "!yes":
+		return false, true
+	}
+	return false, false
+}
+
+// parseConditionNumber 解析条件中的十进制或十六进制数字
+func parseConditionNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || !strings.ContainsAny(s[:1], "0123456789+-.") {
+		return 0, false
+	}
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "0x") {
+		n, err := strconv.ParseInt(lower[2:], 16, 64)
+		return float64(n), err == nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
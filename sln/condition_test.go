@@ -0,0 +1,133 @@
+package sln
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "condition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "exists.props"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := NewPropertySet()
+	ps.Set("Configuration", "Debug")
+	ps.Set("Platform", "x64")
+	ps.Set("UseFoo", "true")
+	ps.Set("OutDir", `bin\`)
+	ps.Set("IntDir", "obj")
+	ps.Set("Version", "16")
+	ctx := ConditionContext{Properties: ps, BaseDir: dir}
+
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"", true},
+		{"  ", true},
+
+		// ==和!=，字符串比较不区分大小写
+		{"'$(Configuration)|$(Platform)'=='Debug|x64'", true},
+		{"'$(Configuration)|$(Platform)'=='Release|x64'", false},
+		{"'$(Configuration)|$(Platform)' == 'DEBUG|X64'", true},
+		{"'$(Configuration)|$(Platform)'!='Release|Win32'", true},
+		{"'$(Configuration)|$(Platform)'!='debug|x64'", false},
+		{"'$(Undefined)'==''", true},
+		{"$(UseFoo)", true},
+		{"'$(UseFoo)'", true},
+		{"false", false},
+
+		// and的优先级高于or，!的优先级最高
+		{"true or false and false", true},
+		{"(true or false) and false", false},
+		{"false and false or true", true},
+		{"!false and false", false},
+		{"!(false and false)", true},
+		{"'$(Configuration)'=='Debug' AND '$(Platform)'=='x64'", true},
+		{"'$(Configuration)'=='Release' Or '$(Platform)'=='x64'", true},
+
+		// 短路求值，被短路的一侧的类型错误不影响结果
+		{"'$(UseFoo)'=='true' or $(Undefined) > 3", true},
+		{"'$(UseFoo)'=='false' and $(Undefined) > 3", false},
+		{"'$(UseFoo)'=='false' and 'abc'", false},
+		{"true or ('x' < 'y' and 'z')", true},
+
+		// Exists和HasTrailingSlash
+		{"Exists('exists.props')", true},
+		{"Exists('" + filepath.Join(dir, "exists.props") + "')", true},
+		{"Exists('missing.props')", false},
+		{"!Exists('missing.props')", true},
+		{"Exists('')", false},
+		{"exists('$(Undefined)')", false},
+		{"HasTrailingSlash('$(OutDir)')", true},
+		{"HasTrailingSlash('$(IntDir)')", false},
+		{"HasTrailingSlash('out/')", true},
+
+		// 数字按数值比较，支持十六进制
+		{"$(Version) >= 16", true},
+		{"'$(Version)' > '9'", true},
+		{"$(Version) < 9", false},
+		{"$(Version) <= 16.0", true},
+		{"1.0 == 1", true},
+		{"0x10 == 16", true},
+		{"'0x1A' > 25", true},
+		{"0XFF != 255", false},
+		{"-1 < 0", true},
+	}
+	for _, tt := range tests {
+		got, err := EvaluateCondition(tt.cond, ctx)
+		if err != nil {
+			t.Errorf("EvaluateCondition(%q) error: %v", tt.cond, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("EvaluateCondition(%q) = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateConditionErrors(t *testing.T) {
+	ps := NewPropertySet()
+	ps.Set("UseFoo", "false")
+	ctx := ConditionContext{Properties: ps, BaseDir: "."}
+
+	tests := []string{
+		// 语法错误
+		"'a'==",
+		"==",
+		"('a'=='a'",
+		"'a'=='a')",
+		"'unterminated",
+		"'a' = 'b'",
+		"'a'=='b' 'c'",
+		"$(Foo=='x'",
+		"true and",
+		"'a'=='a' # 'b'",
+		"Unknown('x')",
+		"Exists()",
+		"Exists('a', 'b')",
+		"HasTrailingSlash()",
+		"Exists('a'",
+		// 求值时的类型错误
+		"'abc'",
+		"'abc' > 3",
+		"$(Undefined) > 3",
+		"'$(UseFoo)'=='true' or $(Undefined) > 3",
+		"'$(UseFoo)'=='false' and 'abc'",
+		// 被短路的一侧仍然检查语法
+		"true or ('a'==",
+		"false and Unknown('x')",
+	}
+	for _, cond := range tests {
+		if got, err := EvaluateCondition(cond, ctx); err == nil {
+			t.Errorf("EvaluateCondition(%q) = %v, want error", cond, got)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package sln
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FlagSet 源文件在当前配置下求值得到的编译参数，与具体的编译器驱动无关
+//
+// 目录、宏和头文件列表不含空项；同时有MSVC和GNU写法的设置保存为Switch，
+// AdditionalOptions按项目文件中的写法保存，由驱动转换为自己的语法。
+type FlagSet struct {
+	// 源文件，相对项目目录
+	File string
+	// 预处理器定义，包括由项目属性决定的默认宏
+	Definitions []string
+	// AdditionalIncludeDirectories、IncludePath和AdditionalUsingDirectories
+	IncludeDirs []string
+	// VC++目录中的ExternalIncludePath
+	ExternalIncludeDirs []string
+	// MSVC标准库和Windows SDK的头文件目录，按VSINSTALLDIR等环境变量确定，未设置时为VS2022的默认安装路径
+	SystemIncludeDirs []string
+	// 为true时SystemIncludeDirs是未设置环境变量时使用的默认安装路径
+	DefaultSystemIncludeDirs bool
+	// 强制包含的头文件，PchForceInclude模式下包括预编译头对应的头文件
+	ForcedIncludes []string
+	// PchMsvc模式下预编译头的用法（Create或Use）、头文件和.pch文件，其他模式下为空
+	PrecompiledHeader           string
+	PrecompiledHeaderFile       string
+	PrecompiledHeaderOutputFile string
+	// PchMsvc模式下预编译头对应的头文件按ForcedIncludes的规则解析后的路径，供不支持/Yu的驱动强制包含
+	PrecompiledHeaderPath string
+	// 编译语言、语言标准、运行库、异常处理等设置
+	Switches []Switch
+	// 警告相关的设置，Options.NoWarningFlags为true时为空
+	Warnings []Switch
+	// DisableSpecificWarnings中的警告编号，Options.NoWarningFlags为true时为空
+	DisabledWarnings []string
+	// AdditionalOptions中的参数，已按Windows命令行规则去掉引号
+	AdditionalOptions []string
+}
+
+// Flags 求值源文件item在当前配置下的编译参数
+func (ev *Evaluation) Flags(item EvaluatedItem) *FlagSet {
+	opts := ev.Project.Options
+	settings := ev.compileSettings(item.Metadata)
+
+	flags := &FlagSet{
+		File:        item.Include,
+		Definitions: splitList(MergeSemicolonSeparatedLists(settings.Definitions, strings.Join(ev.defaultDefinitions(), ";"))),
+		// 处理Conan等包管理器路径
+		IncludeDirs:         splitList(ProcessConanPaths(MergeSemicolonSeparatedLists(settings.IncludeDirs, settings.UsingDirs))),
+		ExternalIncludeDirs: splitList(settings.ExternalIncludeDirs),
+		ForcedIncludes:      splitList(settings.ForcedIncludes),
+		AdditionalOptions:   splitCommandLine(settings.AdditionalOptions),
+	}
+
+	flags.SystemIncludeDirs, flags.DefaultSystemIncludeDirs = systemIncludeDirs()
+
+	switch {
+	case settings.PrecompiledHeader == "" || opts.Pch == PchStrip:
+	case opts.Pch == PchMsvc:
+		flags.PrecompiledHeader = settings.PrecompiledHeader
+		flags.PrecompiledHeaderFile = settings.PrecompiledHeaderFile
+		flags.PrecompiledHeaderOutputFile = settings.PrecompiledHeaderOutputFile
+		flags.PrecompiledHeaderPath = ev.forcedIncludes(settings.PrecompiledHeaderFile)
+	case settings.PrecompiledHeader == "Use":
+		// 创建预编译头的文件自己包含该头文件，只需处理使用预编译头的文件；
+		// 该头文件在其他强制包含的头文件之前，与/Yu隐式包含的顺序一致
+		header := ev.forcedIncludes(settings.PrecompiledHeaderFile)
+		if !containsFold(flags.ForcedIncludes, header) {
+			flags.ForcedIncludes = append([]string{header}, flags.ForcedIncludes...)
+		}
+	}
+
+	switches := ev.selectSwitches(clSwitches, item.Metadata)
+	if settings.IsC(item.Include) {
+		// cl.exe编译C文件时忽略/EHsc、/GR等参数，clang++在C模式下则不接受-fcxx-exceptions等参数
+		switches = withoutGNUFlags(switches, gnuCppOnlyFlags)
+	}
+	flags.Switches = append(languageSwitches(settings, item.Include), switches...)
+	if !opts.NoWarningFlags {
+		flags.Warnings = ev.selectSwitches(warningSwitches, item.Metadata)
+		flags.DisabledWarnings = disabledWarnings(item.Metadata)
+	}
+	return flags
+}
+
+// Driver 编译器驱动，把FlagSet渲染为该驱动的命令行参数
+type Driver interface {
+	// Args 返回编译flags.File的完整命令行参数，第一项为编译器可执行文件
+	Args(flags *FlagSet) []string
+	// CommandLine 把Args返回的参数拼接为compile_commands.json中的command字符串，
+	// 包含空白或引号的参数按该驱动的命令行规则加引号
+	CommandLine(args []string) string
+}
+
+// drivers 已注册的编译器驱动，path为空时驱动使用自己的默认可执行文件
+var drivers = map[DriverKind]func(path string) Driver{
+	DriverClangCl: func(path string) Driver {
+		return &msvcDriver{path: defaultString(path, "clang-cl.exe"), clang: true}
+	},
+	DriverCl: func(path string) Driver {
+		return &msvcDriver{path: defaultString(path, "cl.exe")}
+	},
+	DriverGNU: func(path string) Driver {
+		return &gnuDriver{path: defaultString(path, "clang++")}
+	},
+}
+
+// RegisterDriver 注册自定义的编译器驱动，已存在的同名驱动被替换
+func RegisterDriver(kind DriverKind, factory func(path string) Driver) {
+	drivers[kind] = factory
+}
+
+// NewDriver 创建kind对应的编译器驱动，path为编译器可执行文件的路径，为空时使用驱动的默认值
+func NewDriver(kind DriverKind, path string) (Driver, error) {
+	if kind == "" {
+		kind = DriverClangCl
+	}
+	factory, ok := drivers[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown compiler driver %q, expected %s", kind, strings.Join(driverNames(), ", "))
+	}
+	return factory(path), nil
+}
+
+// driverNames 按名称排序返回已注册的驱动
+func driverNames() []string {
+	var names []string
+	for kind := range drivers {
+		names = append(names, string(kind))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// msvcDriver clang-cl和cl.exe，两者都使用MSVC风格的参数
+type msvcDriver struct {
+	path string
+	// 为true时是clang-cl，否则是cl.exe
+	clang bool
+}
+
+// Args 按MSVC风格输出参数
+//
+// clang-cl沿用-D、-I的写法，外部头文件目录使用-imsvc；cl.exe使用/D、/I和/external:I，
+// 并丢弃AdditionalOptions中只有clang-cl支持的参数。cl.exe从INCLUDE环境变量查找系统头文件，
+// 未设置VSINSTALLDIR等环境变量时不输出MSVC和Windows SDK的默认安装路径。
+func (d *msvcDriver) Args(flags *FlagSet) []string {
+	define, include, compile := "/D", "/I", "/c"
+	if d.clang {
+		define, include, compile = "-D", "-I", "-c"
+	}
+
+	args := []string{d.path}
+	args = appendPrefixed(args, define, flags.Definitions)
+	args = appendPrefixed(args, include, flags.IncludeDirs)
+	if d.clang || !flags.DefaultSystemIncludeDirs {
+		args = appendPrefixed(args, include, flags.SystemIncludeDirs)
+	}
+	for _, dir := range flags.ExternalIncludeDirs {
+		if d.clang {
+			args = append(args, "-imsvc", dir)
+		} else {
+			args = append(args, "/external:I", dir)
+		}
+	}
+
+	if flags.PrecompiledHeader != "" {
+		flag := "/Yu"
+		if flags.PrecompiledHeader == "Create" {
+			flag = "/Yc"
+		}
+		args = append(args, flag+flags.PrecompiledHeaderFile)
+		if flags.PrecompiledHeaderOutputFile != "" {
+			args = append(args, "/Fp"+flags.PrecompiledHeaderOutputFile)
+		}
+	}
+	args = appendPrefixed(args, "/FI", flags.ForcedIncludes)
+
+	// 语言、运行库和警告等参数在AdditionalOptions之前，AdditionalOptions中的设置优先
+	for _, sw := range flags.Switches {
+		args = append(args, strings.Fields(sw.MSVC)...)
+	}
+	for _, sw := range flags.Warnings {
+		args = append(args, strings.Fields(sw.MSVC)...)
+	}
+	args = appendPrefixed(args, "/wd", flags.DisabledWarnings)
+
+	if d.clang {
+		args = append(args, flags.AdditionalOptions...)
+	} else {
+		args = append(args, clOptions(flags.AdditionalOptions)...)
+	}
+	return append(args, compile, flags.File)
+}
+
+// CommandLine 按Windows命令行规则拼接参数
+func (d *msvcDriver) CommandLine(args []string) string {
+	return windowsCommandLine(args)
+}
+
+// gnuDriver clang++、g++等GNU风格的驱动
+type gnuDriver struct {
+	path string
+}
+
+// Args 按GNU风格输出参数
+//
+// 外部头文件目录和系统头文件目录使用-isystem，未设置VSINSTALLDIR等环境变量时不输出MSVC和Windows SDK的
+// 默认安装路径；路径中的分隔符转换为当前系统的分隔符。PchMsvc模式下使用预编译头的文件改为-include该头文件
+// （与PchForceInclude模式一样解析为项目目录下的路径），
+// 创建预编译头的文件不输出参数；DisableSpecificWarnings无法对应MSVC的警告编号，不输出参数。
+func (d *gnuDriver) Args(flags *FlagSet) []string {
+	args := []string{d.path}
+	args = appendPrefixed(args, "-D", flags.Definitions)
+	args = appendPrefixed(args, "-I", nativePaths(flags.IncludeDirs))
+	systemDirs := nativePaths(flags.ExternalIncludeDirs)
+	if !flags.DefaultSystemIncludeDirs {
+		systemDirs = append(systemDirs, flags.SystemIncludeDirs...)
+	}
+	for _, dir := range systemDirs {
+		args = append(args, "-isystem", dir)
+	}
+
+	if flags.PrecompiledHeader == "Use" {
+		args = append(args, "-include", nativePath(flags.PrecompiledHeaderPath))
+	}
+	for _, header := range flags.ForcedIncludes {
+		args = append(args, "-include", nativePath(header))
+	}
+
+	for _, sw := range flags.Switches {
+		args = append(args, strings.Fields(sw.GNU)...)
+	}
+	for _, sw := range flags.Warnings {
+		args = append(args, strings.Fields(sw.GNU)...)
+	}
+	args = append(args, gnuOptions(flags.AdditionalOptions)...)
+	return append(args, "-c", nativePath(flags.File))
+}
+
+// CommandLine 按shell规则拼接参数
+func (d *gnuDriver) CommandLine(args []string) string {
+	return shellCommandLine(args)
+}
+
+// gnuValueOptions 值作为下一个参数的GNU参数
+var gnuValueOptions = map[string]bool{
+	"-isystem": true, "-iquote": true, "-idirafter": true, "-include": true, "-imacros": true,
+	"-isysroot": true, "-Xclang": true, "-x": true,
+}
+
+// gnuOptions 把AdditionalOptions中的MSVC参数转换为GNU风格的参数
+//
+// /D、/U、/I、/FI以及各转换表中出现的参数转换为对应的GNU参数，没有对应参数的MSVC参数被丢弃；
+// 以-开头的参数只在与转换表中的MSVC参数相同时转换，否则视为GNU参数原样保留，-imsvc转换为-isystem。
+// /I、/FI和-imsvc的路径转换为当前系统的分隔符。
+func gnuOptions(opts []string) []string {
+	table := msvcToGNU()
+	var args []string
+	for i := 0; i < len(opts); i++ {
+		opt := opts[i]
+		if len(opt) < 2 || (opt[0] != '/' && opt[0] != '-') {
+			args = append(args, opt)
+			continue
+		}
+		if opt == "-imsvc" || gnuValueOptions[opt] {
+			// 值可能是以/开头的路径，连同值一起保留
+			if opt == "-imsvc" {
+				opt = "-isystem"
+			}
+			args = append(args, opt)
+			if i+1 < len(opts) {
+				i++
+				value := opts[i]
+				if opt == "-isystem" {
+					value = nativePath(value)
+				}
+				args = append(args, value)
+			}
+			continue
+		}
+		name := "/" + opt[1:]
+		if gnu, ok := table[name]; ok {
+			args = append(args, strings.Fields(gnu)...)
+			continue
+		}
+
+		// 带值的参数，值可以紧跟参数名，也可以是下一个参数
+		matched := false
+		for _, p := range []struct{ msvc, gnu string }{
+			{"/FI", "-include"}, {"/D", "-D"}, {"/U", "-U"}, {"/I", "-I"},
+		} {
+			if !strings.HasPrefix(name, p.msvc) {
+				continue
+			}
+			value := name[len(p.msvc):]
+			if value == "" && i+1 < len(opts) {
+				i++
+				value = opts[i]
+			}
+			switch p.gnu {
+			case "-include":
+				args = append(args, p.gnu, nativePath(value))
+			case "-I":
+				args = append(args, p.gnu+nativePath(value))
+			default:
+				args = append(args, p.gnu+value)
+			}
+			matched = true
+			break
+		}
+		if !matched && opt[0] == '-' {
+			args = append(args, opt)
+		}
+	}
+	return args
+}
+
+// clangOnlyOptions 只有clang-cl支持、cl.exe不支持的参数前缀
+var clangOnlyOptions = []string{
+	"/clang:", "-clang:", "/Xclang", "-imsvc", "/imsvc",
+	"-Wno-", "-fno-", "-fms-", "-fdiagnostics-", "-fcolor-diagnostics", "--",
+}
+
+// clOptions 丢弃AdditionalOptions中cl.exe不支持的参数，-imsvc、-Xclang等带值的参数连同其值一起丢弃
+func clOptions(opts []string) []string {
+	var args []string
+	for i := 0; i < len(opts); i++ {
+		opt := opts[i]
+		switch {
+		case opt == "-imsvc" || opt == "/imsvc" || opt == "/Xclang" || gnuValueOptions[opt]:
+			i++
+		case !hasAnyPrefix(opt, clangOnlyOptions):
+			args = append(args, opt)
+		}
+	}
+	return args
+}
+
+// windowsCommandLine 按CommandLineToArgvW的规则拼接参数：包含空白或双引号的参数加双引号，
+// 其中的双引号以及双引号和结尾引号之前的反斜杠转义
+func windowsCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteWindowsArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteWindowsArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\v\"") {
+		return arg
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; c {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			sb.WriteString(strings.Repeat(`\`, backslashes*2+1))
+			sb.WriteByte(c)
+		default:
+			sb.WriteString(strings.Repeat(`\`, backslashes))
+			sb.WriteByte(c)
+		}
+		backslashes = 0
+	}
+	sb.WriteString(strings.Repeat(`\`, backslashes*2))
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// shellSpecialChars 在POSIX shell中有特殊含义、需要加引号的字符
+const shellSpecialChars = " \t\n\"'\\$`!*?[]{}()<>|&;#~"
+
+// shellCommandLine 按POSIX shell的规则拼接参数，包含空白或特殊字符的参数加单引号
+func shellCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, shellSpecialChars) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// splitCommandLine 按CommandLineToArgvW的规则拆分命令行参数
+//
+// 双引号中的空白不拆分，引号本身被去掉；双引号之前的2n个反斜杠变为n个，2n+1个反斜杠变为n个和一个双引号，
+// 其他位置的反斜杠保持原样，因此Windows路径不受影响。
+func splitCommandLine(s string) []string {
+	var args []string
+	var sb strings.Builder
+	inArg, quoted := false, false
+	backslashes := 0
+	for _, c := range s {
+		switch {
+		case c == '\\':
+			backslashes++
+			inArg = true
+			continue
+		case c == '"':
+			sb.WriteString(strings.Repeat(`\`, backslashes/2))
+			if backslashes%2 == 1 {
+				sb.WriteRune(c)
+			} else {
+				quoted = !quoted
+			}
+			inArg = true
+		case !quoted && (c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			sb.WriteString(strings.Repeat(`\`, backslashes))
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteString(strings.Repeat(`\`, backslashes))
+			sb.WriteRune(c)
+			inArg = true
+		}
+		backslashes = 0
+	}
+	sb.WriteString(strings.Repeat(`\`, backslashes))
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return args
+}
+
+// splitList 拆分分号分隔的列表，忽略空项
+func splitList(list string) []string {
+	var items []string
+	for _, v := range strings.Split(list, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+// nativePaths 把路径列表中的Windows路径分隔符转换为当前系统的分隔符
+func nativePaths(list []string) []string {
+	paths := make([]string, 0, len(list))
+	for _, v := range list {
+		paths = append(paths, nativePath(v))
+	}
+	return paths
+}
+
+// appendPrefixed 为list中的每一项加上prefix后追加到args
+func appendPrefixed(args []string, prefix string, list []string) []string {
+	for _, v := range list {
+		args = append(args, prefix+v)
+	}
+	return args
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
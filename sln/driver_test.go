@@ -0,0 +1,260 @@
+package sln
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWindowsCommandLine(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"clang-cl.exe", "-DWIN32", `-I..\inc`, "-c", `src\a.cpp`},
+			`clang-cl.exe -DWIN32 -I..\inc -c src\a.cpp`},
+		{[]string{`-IC:\Program Files\Third\inc`}, `"-IC:\Program Files\Third\inc"`},
+		{[]string{"-imsvc", `C:\Program Files (x86)\Windows Kits\um\`}, `-imsvc "C:\Program Files (x86)\Windows Kits\um\\"`},
+		{[]string{`-DNAME="a b"`}, `"-DNAME=\"a b\""`},
+		{[]string{`-DPATH=\"x\"`}, `"-DPATH=\\\"x\\\""`},
+		{[]string{""}, `""`},
+	}
+	for _, tt := range tests {
+		got := windowsCommandLine(tt.args)
+		if got != tt.want {
+			t.Errorf("windowsCommandLine(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+		// 按Windows规则拆分后应得到原来的参数
+		if back := splitCommandLine(got); !reflect.DeepEqual(back, tt.args) {
+			t.Errorf("splitCommandLine(%s) = %q, want %q", got, back, tt.args)
+		}
+	}
+}
+
+func TestShellCommandLine(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"clang++", "-DWIN32", "-I../inc", "-std=c++17", "-c", "src/a.cpp"},
+			"clang++ -DWIN32 -I../inc -std=c++17 -c src/a.cpp"},
+		{[]string{"-I/opt/Third Party/inc"}, "'-I/opt/Third Party/inc'"},
+		{[]string{`-DNAME="a b"`}, `'-DNAME="a b"'`},
+		{[]string{"-DQUOTE='x'"}, `'-DQUOTE='\''x'\'''`},
+		{[]string{`-Isrc\inc`, "-D$(X)"}, `'-Isrc\inc' '-D$(X)'`},
+		{[]string{""}, "''"},
+	}
+	for _, tt := range tests {
+		if got := shellCommandLine(tt.args); got != tt.want {
+			t.Errorf("shellCommandLine(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"/bigobj  /utf-8\t/Zc:__cplusplus", []string{"/bigobj", "/utf-8", "/Zc:__cplusplus"}},
+		{`/I"C:\Program Files\x" /DA`, []string{`/IC:\Program Files\x`, "/DA"}},
+		{`"/DNAME=\"a b\""`, []string{`/DNAME="a b"`}},
+		{`/I"C:\dir\\" /DA`, []string{`/IC:\dir\`, "/DA"}},
+		{`/Ic:\a\b`, []string{`/Ic:\a\b`}},
+		{`"" x`, []string{"", "x"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := splitCommandLine(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommandLine(%s) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+// evaluateTestProject 在临时目录中创建只有Debug|x64配置的项目并求值，body为Project元素中的其他内容
+func evaluateTestProject(t *testing.T, body string) (*Evaluation, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "driver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemGroup Label="ProjectConfigurations">
+    <ProjectConfiguration Include="Debug|x64" />
+  </ItemGroup>
+` + body + `
+</Project>`
+	path := filepath.Join(dir, "test.vcxproj")
+	if err := ioutil.WriteFile(path, []byte(project), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	pro, err := NewProject(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	pro.Options.NoDirectoryBuild = true
+	ev, err := pro.Evaluate("Debug|x64")
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return ev, func() { os.RemoveAll(dir) }
+}
+
+// gnuArgs 返回GNU驱动为项目中每个ClCompile项生成的参数，键为项的Include
+func gnuArgs(ev *Evaluation) map[string]string {
+	driver, _ := NewDriver(DriverGNU, "")
+	args := map[string]string{}
+	for _, item := range ev.Items("ClCompile") {
+		args[item.Include] = " " + strings.Join(driver.Args(ev.Flags(item)), " ") + " "
+	}
+	return args
+}
+
+func TestGNUDriverLanguage(t *testing.T) {
+	ev, cleanup := evaluateTestProject(t, `
+  <ItemDefinitionGroup>
+    <ClCompile>
+      <LanguageStandard>stdcpp17</LanguageStandard>
+      <LanguageStandard_C>stdc11</LanguageStandard_C>
+      <RuntimeTypeInfo>true</RuntimeTypeInfo>
+      <TreatWChar_tAsBuiltInType>false</TreatWChar_tAsBuiltInType>
+    </ClCompile>
+  </ItemDefinitionGroup>
+  <ItemGroup>
+    <ClCompile Include="src\a.cpp" />
+    <ClCompile Include="src\b.c" />
+    <ClCompile Include="src\c.c">
+      <CompileAs>CompileAsCpp</CompileAs>
+    </ClCompile>
+  </ItemGroup>`)
+	defer cleanup()
+	args := gnuArgs(ev)
+
+	tests := []struct {
+		file    string
+		want    []string
+		notWant []string
+	}{
+		{`src\a.cpp`, []string{" -x c++ ", " -std=c++17 ", " -fcxx-exceptions ", " -frtti ", " -fno-wchar "}, []string{" -x c ", "-std=c11"}},
+		{`src\b.c`, []string{" -x c ", " -std=c11 ", " -fexceptions "},
+			[]string{" -x c++ ", "-std=c++", "-fcxx-exceptions", "-frtti", "-fno-wchar"}},
+		{`src\c.c`, []string{" -x c++ ", " -std=c++17 ", " -fcxx-exceptions "}, []string{" -x c ", "-std=c11"}},
+	}
+	for _, tt := range tests {
+		got, ok := args[tt.file]
+		if !ok {
+			t.Errorf("%s: no ClCompile item", tt.file)
+			continue
+		}
+		for _, v := range tt.want {
+			if !strings.Contains(got, v) {
+				t.Errorf("%s: args %q do not contain %q", tt.file, got, v)
+			}
+		}
+		for _, v := range tt.notWant {
+			if strings.Contains(got, v) {
+				t.Errorf("%s: args %q contain %q", tt.file, got, v)
+			}
+		}
+		// -x必须在源文件之前
+		if strings.Index(got, " -x ") > strings.Index(got, " -c ") {
+			t.Errorf("%s: -x after the source file in %q", tt.file, got)
+		}
+	}
+}
+
+func TestGNUDriverPaths(t *testing.T) {
+	for _, name := range []string{"VSINSTALLDIR", "WindowsSdkDir"} {
+		if v, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			defer os.Setenv(name, v)
+		}
+	}
+	ev, cleanup := evaluateTestProject(t, `
+  <ItemDefinitionGroup>
+    <ClCompile>
+      <AdditionalIncludeDirectories>..\inc;third\include</AdditionalIncludeDirectories>
+      <ForcedIncludeFiles>common\pre.h</ForcedIncludeFiles>
+      <AdditionalOptions>/Ilib\inc /FIcfg\config.h</AdditionalOptions>
+    </ClCompile>
+  </ItemDefinitionGroup>
+  <ItemGroup>
+    <ClCompile Include="src\a.cpp" />
+  </ItemGroup>`)
+	defer cleanup()
+	got := gnuArgs(ev)[`src\a.cpp`]
+
+	// 未设置环境变量时不输出MSVC和Windows SDK的默认安装路径
+	if strings.Contains(got, "Program Files") {
+		t.Errorf("args %q contain the default MSVC system include dirs", got)
+	}
+	sep := string(filepath.Separator)
+	want := []string{
+		" -I.." + sep + "inc ",
+		" -Ithird" + sep + "include ",
+		" -include common" + sep + "pre.h ",
+		" -Ilib" + sep + "inc ",
+		" -include cfg" + sep + "config.h ",
+		" -c src" + sep + "a.cpp ",
+	}
+	for _, v := range want {
+		if !strings.Contains(got, v) {
+			t.Errorf("args %q do not contain %q", got, v)
+		}
+	}
+}
+
+func TestDriverOptionsAndPch(t *testing.T) {
+	for _, name := range []string{"VSINSTALLDIR", "WindowsSdkDir"} {
+		if v, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			defer os.Setenv(name, v)
+		}
+	}
+	ev, cleanup := evaluateTestProject(t, `
+  <ItemDefinitionGroup>
+    <ClCompile>
+      <PrecompiledHeader>Use</PrecompiledHeader>
+      <PrecompiledHeaderFile>pch.h</PrecompiledHeaderFile>
+      <AdditionalOptions>/DLIST=a;b . %(AdditionalOptions)</AdditionalOptions>
+    </ClCompile>
+  </ItemDefinitionGroup>
+  <ItemGroup>
+    <ClCompile Include="a.cpp" />
+  </ItemGroup>`)
+	defer cleanup()
+	ev.Project.Options.Pch = PchMsvc
+	if err := ioutil.WriteFile(filepath.Join(ev.Project.ProjectDir, "pch.h"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	items := ev.Items("ClCompile")
+	flags := ev.Flags(items[0])
+
+	if want := []string{"/DLIST=a;b", "."}; !reflect.DeepEqual(flags.AdditionalOptions, want) {
+		t.Errorf("AdditionalOptions = %q, want %q", flags.AdditionalOptions, want)
+	}
+
+	// gnu驱动强制包含解析后的预编译头路径
+	gnu, _ := NewDriver(DriverGNU, "")
+	got := " " + strings.Join(gnu.Args(flags), " ") + " "
+	if want := " -include " + filepath.Join(ev.Project.ProjectDir, "pch.h") + " "; !strings.Contains(got, want) {
+		t.Errorf("gnu args %q do not contain %q", got, want)
+	}
+
+	// cl驱动不输出默认的系统头文件目录，clang-cl保持输出
+	cl, _ := NewDriver(DriverCl, "")
+	if got := strings.Join(cl.Args(flags), " "); strings.Contains(got, "Program Files") {
+		t.Errorf("cl args %q contain the default MSVC system include dirs", got)
+	}
+	clang, _ := NewDriver(DriverClangCl, "")
+	if got := strings.Join(clang.Args(flags), " "); !strings.Contains(got, "Program Files") {
+		t.Errorf("clang-cl args %q do not contain the default MSVC system include dirs", got)
+	}
+}
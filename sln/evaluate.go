@@ -0,0 +1,552 @@
+package sln
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Evaluation 项目在某个配置下的求值结果
+type Evaluation struct {
+	Project       *Project
+	Configuration string
+	Platform      string
+	Properties    *PropertySet
+	// 按导入顺序排列的导入文件路径
+	Imports []string
+
+	// 当前正在处理的文件，用于MSBuildThisFile*属性和相对路径
+	file *Project
+	// 正在导入的文件链，用于检测循环导入
+	importStack []string
+	// 第一遍求值时按顺序收集的ItemDefinitionGroup和ItemGroup
+	definitionGroups []scopedDefinitionGroup
+	itemGroups       []scopedItemGroup
+	// 尚未导入Directory.Build.props，在项目文件的第一个Import之前导入
+	pendingDirectoryBuildProps bool
+	// defaultDefinitions的结果，第一次调用时计算
+	defaultDefs []string
+}
+
+// 求值过程中收集的ItemDefinitionGroup及其所在的文件
+type scopedDefinitionGroup struct {
+	file  *Project
+	group ItemDefinitionGroup
+}
+
+// 求值过程中收集的ItemGroup及其所在的文件
+type scopedItemGroup struct {
+	file  *Project
+	group ItemGroup
+}
+
+// Evaluate 按MSBuild的规则在指定配置下求值项目，conf格式为Configuration|Platform
+//
+// 第一遍按文档顺序处理PropertyGroup和Import，导入的文件递归展开，
+// 后定义的属性覆盖先定义的属性，定义时即展开对其他属性的引用，
+// 环境变量作为最低优先级的来源；ItemDefinitionGroup和ItemGroup
+// 在所有属性确定之后再求值。
+//
+// 与MSBuild在Microsoft.Common.props中导入Directory.Build.props一致，该文件在项目的第一个Import之前导入，
+// 项目中位于其前面的属性（例如Globals中的ImportDirectoryBuildProps）可以禁用导入或指定其路径。
+func (pro *Project) Evaluate(conf string) (*Evaluation, error) {
+	matchedConfig, err := pro.matchConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	vlist := strings.SplitN(matchedConfig, "|", 2)
+	ev := &Evaluation{
+		Project:       pro,
+		Configuration: vlist[0],
+	}
+	if len(vlist) == 2 {
+		ev.Platform = vlist[1]
+	}
+	ev.Properties = pro.initialProperties(ev.Configuration, ev.Platform)
+
+	restore := ev.enterFile(pro)
+	ev.importStack = []string{pro.ProjectPath}
+	// VCBuild不会导入Directory.Build.*，只对MSBuild项目生效
+	directoryBuild := !pro.Options.NoDirectoryBuild && !pro.isVcproj()
+	ev.pendingDirectoryBuildProps = directoryBuild
+	ev.evaluateElements(pro)
+	// 项目中没有Import时在项目末尾导入
+	ev.importDirectoryBuildProps()
+	if directoryBuild {
+		ev.importDirectoryBuild("Directory.Build.targets", "ImportDirectoryBuildTargets", "DirectoryBuildTargetsPath")
+	}
+	restore()
+	return ev, nil
+}
+
+// initialProperties 构建求值前就已确定的全局属性和保留属性
+func (pro *Project) initialProperties(configuration, platform string) *PropertySet {
+	ps := NewPropertySet()
+
+	solutionDir := pro.SolutionDir
+	if solutionDir == "" {
+		solutionDir = pro.ProjectDir
+	}
+	fileName := filepath.Base(pro.ProjectPath)
+	ext := filepath.Ext(fileName)
+	name := strings.TrimSuffix(fileName, ext)
+
+	ps.SetGlobal("Configuration", configuration)
+	ps.SetGlobal("Platform", platform)
+	ps.SetGlobal("SolutionDir", withTrailingSeparator(solutionDir))
+
+	// MSBuild保留属性，项目文件不能修改
+	ps.SetGlobal("MSBuildProjectDirectory", pro.ProjectDir)
+	ps.SetGlobal("MSBuildProjectFullPath", pro.ProjectPath)
+	ps.SetGlobal("MSBuildProjectFile", fileName)
+	ps.SetGlobal("MSBuildProjectName", name)
+	ps.SetGlobal("MSBuildProjectExtension", ext)
+
+	// Microsoft.Common.props中定义的常用属性，项目文件可以覆盖
+	ps.Set("ConfigurationName", configuration)
+	ps.Set("PlatformName", platform)
+	ps.Set("ProjectDir", withTrailingSeparator(pro.ProjectDir))
+	ps.Set("ProjectPath", pro.ProjectPath)
+	ps.Set("ProjectFileName", fileName)
+	ps.Set("ProjectName", name)
+	ps.Set("ProjectExt", ext)
+	return ps
+}
+
+// enterFile 切换当前处理的文件并设置MSBuildThisFile*属性，返回恢复函数
+func (ev *Evaluation) enterFile(file *Project) func() {
+	prev := ev.file
+	ev.setThisFile(file)
+	return func() {
+		ev.setThisFile(prev)
+	}
+}
+
+func (ev *Evaluation) setThisFile(file *Project) {
+	ev.file = file
+	if file == nil {
+		return
+	}
+	ev.Properties.source = file.ProjectPath
+	fileName := filepath.Base(file.ProjectPath)
+	ext := filepath.Ext(fileName)
+	ev.Properties.SetGlobal("MSBuildThisFile", fileName)
+	ev.Properties.SetGlobal("MSBuildThisFileName", strings.TrimSuffix(fileName, ext))
+	ev.Properties.SetGlobal("MSBuildThisFileExtension", ext)
+	ev.Properties.SetGlobal("MSBuildThisFileFullPath", file.ProjectPath)
+	ev.Properties.SetGlobal("MSBuildThisFileDirectory", withTrailingSeparator(file.ProjectDir))
+}
+
+// evaluateFile 在导入文件的上下文中进行第一遍求值
+func (ev *Evaluation) evaluateFile(file *Project) {
+	restore := ev.enterFile(file)
+	defer restore()
+
+	ev.importStack = append(ev.importStack, file.ProjectPath)
+	defer func() {
+		ev.importStack = ev.importStack[:len(ev.importStack)-1]
+	}()
+
+	ev.evaluateElements(file)
+}
+
+// evaluateElements 第一遍求值：按文档顺序处理文件中的顶层元素
+func (ev *Evaluation) evaluateElements(file *Project) {
+	for _, el := range file.elements {
+		if file == ev.Project && (el.kind == elementImport || el.kind == elementImportGroup) {
+			ev.importDirectoryBuildProps()
+		}
+		switch el.kind {
+		case elementPropertyGroup:
+			ev.applyPropertyGroup(file.PropertyGroup[el.index])
+		case elementImport:
+			ev.applyImport(file.Import[el.index])
+		case elementImportGroup:
+			group := file.ImportGroup[el.index]
+			if !ev.conditionHolds(group.Condition) {
+				continue
+			}
+			for _, imp := range group.Import {
+				ev.applyImport(imp)
+			}
+		case elementItemDefinitionGroup:
+			ev.definitionGroups = append(ev.definitionGroups,
+				scopedDefinitionGroup{file, file.ItemDefinitionGroup[el.index]})
+		case elementItemGroup:
+			ev.itemGroups = append(ev.itemGroups,
+				scopedItemGroup{file, file.ItemGroup[el.index]})
+		case elementChoose:
+			ev.applyChoose(file, file.Choose[el.index])
+		}
+	}
+}
+
+// applyChoose 选择第一个条件成立的When，都不成立时选择Otherwise，并按文档顺序处理其中的元素
+//
+// 分支在第一遍求值时确定，选中分支中的ItemGroup与顶层的ItemGroup一样在属性确定之后再求值。
+func (ev *Evaluation) applyChoose(file *Project, choose Choose) {
+	branch := choose.Otherwise
+	for i := range choose.When {
+		if ev.conditionHolds(choose.When[i].Condition) {
+			branch = &choose.When[i]
+			break
+		}
+	}
+	if branch == nil {
+		return
+	}
+	for _, el := range branch.elements {
+		switch el.kind {
+		case elementPropertyGroup:
+			ev.applyPropertyGroup(branch.PropertyGroup[el.index])
+		case elementItemGroup:
+			ev.itemGroups = append(ev.itemGroups,
+				scopedItemGroup{file, branch.ItemGroup[el.index]})
+		case elementChoose:
+			ev.applyChoose(file, branch.Choose[el.index])
+		}
+	}
+}
+
+// applyPropertyGroup 按文档顺序把PropertyGroup中的属性写入属性集
+func (ev *Evaluation) applyPropertyGroup(group PropertyGroup) {
+	if !ev.conditionHolds(group.Condition) {
+		return
+	}
+	for _, p := range group.Properties {
+		if !ev.conditionHolds(p.Condition) {
+			continue
+		}
+		ev.Properties.Set(p.Name(), ev.Properties.Expand(strings.TrimSpace(p.Value)))
+	}
+}
+
+// applyImport 加载Import引用的文件并递归求值，相对路径基于当前文件所在目录
+//
+// 不存在的文件直接忽略（例如未安装VC++构建工具时的Microsoft.Cpp.props），
+// 循环导入和重复导入会输出警告并跳过。
+func (ev *Evaluation) applyImport(imp Import) {
+	if !ev.conditionHolds(imp.Condition) {
+		return
+	}
+	for _, path := range strings.Split(ev.Properties.Expand(imp.Project), ";") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		path = ev.resolvePath(path)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		if containsPath(ev.importStack, path) {
+			fmt.Fprintf(os.Stderr, "Warning: %s: import cycle detected, skipping %s\n", ev.file.ProjectPath, path)
+			continue
+		}
+		if containsPath(ev.Imports, path) {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %s is imported more than once, skipping\n", ev.file.ProjectPath, path)
+			continue
+		}
+
+		file, err := ev.Project.loadImport(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", ev.file.ProjectPath, err)
+			continue
+		}
+		ev.Imports = append(ev.Imports, path)
+		ev.evaluateFile(file)
+	}
+}
+
+// importDirectoryBuild 导入从项目目录向上找到的第一个name文件，
+// 与Microsoft.Common.props/targets隐式导入Directory.Build.*的行为一致
+//
+// enableProp为false时不导入，pathProp非空时直接使用其指定的文件。
+func (ev *Evaluation) importDirectoryBuild(name, enableProp, pathProp string) {
+	if strings.EqualFold(strings.TrimSpace(ev.Properties.Get(enableProp)), "false") {
+		return
+	}
+	path := ev.Properties.Get(pathProp)
+	if path == "" {
+		dir := findFileAbove(ev.Project.ProjectDir, name)
+		if dir == "" {
+			return
+		}
+		path = filepath.Join(dir, name)
+	}
+	ev.Properties.Set(pathProp, path)
+	ev.applyImport(Import{Project: path})
+}
+
+// importDirectoryBuildProps 导入尚未导入的Directory.Build.props
+func (ev *Evaluation) importDirectoryBuildProps() {
+	if !ev.pendingDirectoryBuildProps {
+		return
+	}
+	ev.pendingDirectoryBuildProps = false
+	ev.importDirectoryBuild("Directory.Build.props", "ImportDirectoryBuildProps", "DirectoryBuildPropsPath")
+}
+
+// findFileAbove 从dir开始逐级向上查找name文件，返回其所在目录，找不到返回空字符串
+func findFileAbove(dir, name string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadImport 解析导入的文件，同一文件只解析一次
+func (pro *Project) loadImport(path string) (*Project, error) {
+	if file, ok := pro.imports[path]; ok {
+		return file, nil
+	}
+	file, err := parseProjectFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if pro.imports != nil {
+		pro.imports[path] = file
+	}
+	return file, nil
+}
+
+// resolvePath 把相对路径转换为基于当前文件目录的绝对路径
+func (ev *Evaluation) resolvePath(path string) string {
+	path = nativePath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ev.file.ProjectDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// definition 按顺序合并当前配置下所有条件成立的ItemDefinitionGroup中itemType的默认元数据，
+// 包括导入文件中的分组，没有任何分组匹配时第二个返回值为false
+//
+// 与MSBuild一致，后定义的元数据覆盖先定义的，其中的%(Name)引用展开为此前已累积的值，
+// 因此无条件的分组和导入文件中的分组可以在前面分组的基础上扩展列表。
+func (ev *Evaluation) definition(itemType string) (*MetadataSet, bool) {
+	md := NewMetadataSet()
+	found := false
+	for _, scoped := range ev.definitionGroups {
+		restore := ev.enterFile(scoped.file)
+		if ev.conditionHolds(scoped.group.Condition) {
+			found = true
+			for _, def := range scoped.group.Definitions {
+				if !strings.EqualFold(def.Type(), itemType) || !ev.conditionHolds(def.Condition) {
+					continue
+				}
+				for _, m := range ev.evaluateMetadata(def.Metadata) {
+					md.Set(m.Name(), expandMetadataRefs(m.Value, itemType, md.Get))
+				}
+			}
+		}
+		restore()
+	}
+	return md, found
+}
+
+// evaluateMetadata 在当前文件的上下文中求值元数据，返回条件成立且已展开属性引用的元数据
+func (ev *Evaluation) evaluateMetadata(list []Metadata) []Metadata {
+	var metadata []Metadata
+	for _, m := range list {
+		if ev.conditionHolds(m.Condition) {
+			m.Condition = ""
+			m.Value = ev.Properties.Expand(strings.TrimSpace(m.Value))
+			metadata = append(metadata, m)
+		}
+	}
+	return metadata
+}
+
+// metadataRef 匹配%(Name)和%(ItemType.Name)形式的元数据引用
+var metadataRef = regexp.MustCompile(`%\(\s*(?:([A-Za-z_][\w-]*)\s*\.\s*)?([A-Za-z_][\w-]*)\s*\)`)
+
+// expandMetadataRefs 展开s中的元数据引用，lookup返回itemType类型元数据的当前值
+//
+// 未定义的元数据和其他项类型的元数据展开为空字符串，展开后列表中留下的空项由使用方忽略。
+func expandMetadataRefs(s, itemType string, lookup func(name string) string) string {
+	if !strings.Contains(s, "%(") {
+		return s
+	}
+	return metadataRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := metadataRef.FindStringSubmatch(ref)
+		if m[1] != "" && !strings.EqualFold(m[1], itemType) {
+			return ""
+		}
+		return lookup(m[2])
+	})
+}
+
+// Items 返回当前配置下生效的itemType类型的项，项类型不区分大小写
+//
+// 按求值顺序处理Include、Remove和Update：Include中的通配符基于项目目录展开并应用Exclude，
+// 每个文件对应一项；Remove从已有的项中删除匹配的文件，Update为已有的匹配项追加元数据。
+// 每一项以ItemDefinitionGroup的合并结果为基础，再按顺序应用自身的元数据，
+// %(Name)引用展开为继承来的值，因此每一项的设置只影响它自己。
+func (ev *Evaluation) Items(itemType string) []EvaluatedItem {
+	defaults, _ := ev.definition(itemType)
+
+	var items []EvaluatedItem
+	for _, scoped := range ev.itemGroups {
+		restore := ev.enterFile(scoped.file)
+		if ev.conditionHolds(scoped.group.Condition) {
+			for _, item := range scoped.group.Items {
+				if !strings.EqualFold(item.Type(), itemType) || !ev.conditionHolds(item.Condition) {
+					continue
+				}
+				switch {
+				case strings.TrimSpace(item.Include) != "":
+					items = append(items, ev.includeItems(item, defaults)...)
+				case strings.TrimSpace(item.Remove) != "":
+					items = ev.removeItems(items, item)
+				case strings.TrimSpace(item.Update) != "":
+					ev.updateItems(items, item)
+				}
+			}
+		}
+		restore()
+	}
+	return items
+}
+
+// includeItems 展开Include中的每个文件，返回排除Exclude之后的项
+func (ev *Evaluation) includeItems(item Item, defaults *MetadataSet) []EvaluatedItem {
+	baseDir := ev.Project.ProjectDir
+	excludes := ev.itemSpecs(item.Exclude)
+	metadata := ev.evaluateMetadata(item.Metadata)
+
+	var items []EvaluatedItem
+	for _, spec := range ev.itemSpecs(item.Include) {
+		paths := []string{spec}
+		if hasWildcard(spec) {
+			paths = expandGlob(baseDir, spec)
+		}
+		for _, path := range paths {
+			if len(excludes) > 0 && matchItemSpec(baseDir, path, excludes) {
+				continue
+			}
+			v := EvaluatedItem{
+				Type:     item.Type(),
+				Include:  path,
+				Metadata: defaults.Clone(),
+			}
+			ev.applyMetadata(&v, metadata)
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+// removeItems 删除与Remove匹配的项
+func (ev *Evaluation) removeItems(items []EvaluatedItem, item Item) []EvaluatedItem {
+	specs := ev.itemSpecs(item.Remove)
+	kept := items[:0]
+	for _, v := range items {
+		if !matchItemSpec(ev.Project.ProjectDir, v.Include, specs) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// updateItems 为与Update匹配的项追加元数据，元数据在Update所在文件的上下文中求值
+func (ev *Evaluation) updateItems(items []EvaluatedItem, item Item) {
+	specs := ev.itemSpecs(item.Update)
+	metadata := ev.evaluateMetadata(item.Metadata)
+	for i := range items {
+		if matchItemSpec(ev.Project.ProjectDir, items[i].Include, specs) {
+			ev.applyMetadata(&items[i], metadata)
+		}
+	}
+}
+
+// applyMetadata 按顺序应用已求值的元数据，%(Name)引用展开为继承来的值，
+// 也可以引用%(Filename)等内置元数据
+func (ev *Evaluation) applyMetadata(item *EvaluatedItem, metadata []Metadata) {
+	lookup := func(name string) string {
+		return ev.GetMetadata(*item, name)
+	}
+	for _, m := range metadata {
+		item.Metadata.Set(m.Name(), expandMetadataRefs(m.Value, item.Type, lookup))
+	}
+}
+
+// itemSpecs 展开属性引用并按分号拆分项规格
+func (ev *Evaluation) itemSpecs(spec string) []string {
+	var specs []string
+	for _, v := range strings.Split(ev.Properties.Expand(spec), ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			specs = append(specs, v)
+		}
+	}
+	return specs
+}
+
+// SourceFiles 返回当前配置下参与编译的源文件，不包括被ExcludedFromBuild排除的文件
+func (ev *Evaluation) SourceFiles() []string {
+	var fileList []string
+	for _, item := range ev.Items("ClCompile") {
+		if !ev.excludedFromBuild(item) {
+			fileList = append(fileList, item.Include)
+		}
+	}
+	return fileList
+}
+
+// excludedFromBuild 判断项是否在当前配置下被ExcludedFromBuild排除
+func (ev *Evaluation) excludedFromBuild(item EvaluatedItem) bool {
+	return isTrue(ev.GetMetadata(item, "ExcludedFromBuild"))
+}
+
+// conditionHolds 判断Condition属性是否成立，无法解析的条件视为不成立
+func (ev *Evaluation) conditionHolds(cond string) bool {
+	ok, err := EvaluateCondition(cond, ConditionContext{
+		Properties: ev.Properties,
+		BaseDir:    ev.file.ProjectDir,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", ev.file.ProjectPath, err)
+		return false
+	}
+	return ok
+}
+
+// containsPath 判断路径列表中是否包含path，Windows下不区分大小写
+func containsPath(list []string, path string) bool {
+	for _, v := range list {
+		if v == path || (filepath.Separator == '\\' && strings.EqualFold(v, path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathKey 路径的比较键，项目文件来自Windows，不区分大小写
+func pathKey(path string) string {
+	return strings.ToLower(filepath.Clean(path))
+}
+
+// nativePath 把项目文件中的Windows路径分隔符转换为当前系统的分隔符
+func nativePath(path string) string {
+	if filepath.Separator == '\\' {
+		return path
+	}
+	return strings.Replace(path, "\\", string(filepath.Separator), -1)
+}
+
+// withTrailingSeparator 确保目录以路径分隔符结尾，与MSBuild中$(ProjectDir)等属性的格式一致
+func withTrailingSeparator(dir string) string {
+	if dir == "" || strings.HasSuffix(dir, "/") || strings.HasSuffix(dir, "\\") {
+		return dir
+	}
+	return dir + string(filepath.Separator)
+}
@@ -0,0 +1,69 @@
+package sln
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectoryBuildProps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "evaluate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"Directory.Build.props": `<Project><PropertyGroup><FromProps>root</FromProps></PropertyGroup></Project>`,
+		"custom.props":          `<Project><PropertyGroup><FromProps>custom</FromProps></PropertyGroup></Project>`,
+		"common.props":          `<Project><PropertyGroup><Seen>$(FromProps)</Seen></PropertyGroup></Project>`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		globals string
+		want    string
+	}{
+		{"default", ``, "root"},
+		{"opt-out", `<ImportDirectoryBuildProps>false</ImportDirectoryBuildProps>`, ""},
+		{"path", `<DirectoryBuildPropsPath>$(MSBuildThisFileDirectory)..\custom.props</DirectoryBuildPropsPath>`, "custom"},
+	}
+	for _, tt := range tests {
+		// Directory.Build.props在Globals之后、第一个Import之前导入
+		project := `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemGroup Label="ProjectConfigurations">
+    <ProjectConfiguration Include="Debug|x64" />
+  </ItemGroup>
+  <PropertyGroup Label="Globals">` + tt.globals + `</PropertyGroup>
+  <Import Project="..\common.props" />
+</Project>`
+		path := filepath.Join(dir, "app", tt.name+".vcxproj")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(project), 0644); err != nil {
+			t.Fatal(err)
+		}
+		pro, err := NewProject(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev, err := pro.Evaluate("Debug|x64")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ev.Properties.Get("Seen"); got != tt.want {
+			t.Errorf("%s: FromProps seen by the first import = %q, want %q", tt.name, got, tt.want)
+		}
+		if got := ev.Properties.Get("FromProps"); got != tt.want {
+			t.Errorf("%s: FromProps = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
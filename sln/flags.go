@@ -0,0 +1,228 @@
+package sln
+
+import "strings"
+
+// Switch 一个编译设置对应的编译器参数，多个参数以空格分隔，为空表示该风格没有对应的参数
+type Switch struct {
+	// clang-cl和cl.exe使用的参数
+	MSVC string
+	// clang++等GNU风格驱动使用的参数
+	GNU string
+}
+
+// clSwitch ClCompile元数据到编译器参数的转换
+type clSwitch struct {
+	// 元数据名
+	metadata string
+	// 小写的取值到参数的转换，表中没有的取值（包括Default）不输出参数
+	values map[string]Switch
+	// 元数据未设置时使用的值，与Microsoft.Cl.Common.props中的默认值一致，为nil时不输出参数
+	defaultValue func(ev *Evaluation) string
+}
+
+// clSwitches 影响预定义宏或语义、clangd需要知道的ClCompile设置
+//
+// GNU风格的驱动没有/MD等运行库参数，改为直接定义cl.exe在这些参数下预定义的宏。
+var clSwitches = []clSwitch{
+	{
+		metadata: "RuntimeLibrary",
+		values: map[string]Switch{
+			"multithreaded":         {"/MT", "-D_MT"},
+			"multithreadeddebug":    {"/MTd", "-D_MT -D_DEBUG"},
+			"multithreadeddll":      {"/MD", "-D_MT -D_DLL"},
+			"multithreadeddebugdll": {"/MDd", "-D_MT -D_DLL -D_DEBUG"},
+		},
+		defaultValue: func(ev *Evaluation) string {
+			if ev.isDebug() {
+				return "MultiThreadedDebugDLL"
+			}
+			return "MultiThreadedDLL"
+		},
+	},
+	{
+		metadata: "ExceptionHandling",
+		values: map[string]Switch{
+			"sync":       {"/EHsc", "-fexceptions -fcxx-exceptions"},
+			"synccthrow": {"/EHs", "-fexceptions -fcxx-exceptions"},
+			"async":      {"/EHa", "-fexceptions -fcxx-exceptions -fasync-exceptions"},
+			"false":      {"/EHs-c-", "-fno-exceptions"},
+		},
+		defaultValue: func(ev *Evaluation) string {
+			return "Sync"
+		},
+	},
+	{
+		metadata: "RuntimeTypeInfo",
+		values: map[string]Switch{
+			"true":  {"/GR", "-frtti"},
+			"false": {"/GR-", "-fno-rtti"},
+		},
+	},
+	{
+		metadata: "ConformanceMode",
+		values: map[string]Switch{
+			// GNU风格的驱动本身就是严格模式
+			"true": {"/permissive-", ""},
+		},
+	},
+	{
+		metadata: "TreatWChar_tAsBuiltInType",
+		values: map[string]Switch{
+			"true":  {"/Zc:wchar_t", ""},
+			"false": {"/Zc:wchar_t-", "-fno-wchar"},
+		},
+	},
+	{
+		metadata: "StructMemberAlignment",
+		values: map[string]Switch{
+			"1byte":   {"/Zp1", "-fpack-struct=1"},
+			"2bytes":  {"/Zp2", "-fpack-struct=2"},
+			"4bytes":  {"/Zp4", "-fpack-struct=4"},
+			"8bytes":  {"/Zp8", "-fpack-struct=8"},
+			"16bytes": {"/Zp16", "-fpack-struct=16"},
+		},
+	},
+}
+
+// warningSwitches 警告相关的ClCompile设置，DisableSpecificWarnings单独处理
+//
+// GNU风格的参数与clang-cl内部的转换一致；ExternalWarningLevel在GNU风格下没有对应的参数，
+// 外部头文件目录本身不报告警告。
+var warningSwitches = []clSwitch{
+	{
+		metadata: "WarningLevel",
+		values: map[string]Switch{
+			"turnoffallwarnings": {"/W0", "-w"},
+			"level1":             {"/W1", "-Wall"},
+			"level2":             {"/W2", "-Wall"},
+			"level3":             {"/W3", "-Wall"},
+			"level4":             {"/W4", "-Wall -Wextra"},
+			"enableallwarnings":  {"/Wall", "-Weverything"},
+		},
+	},
+	{
+		metadata: "TreatWarningAsError",
+		values: map[string]Switch{
+			"true": {"/WX", "-Werror"},
+		},
+	},
+	{
+		metadata: "ExternalWarningLevel",
+		values: map[string]Switch{
+			"turnoffallwarnings": {"/external:W0", ""},
+			"level1":             {"/external:W1", ""},
+			"level2":             {"/external:W2", ""},
+			"level3":             {"/external:W3", ""},
+			"level4":             {"/external:W4", ""},
+		},
+	},
+}
+
+// LanguageStandard和LanguageStandard_C对应的参数，Default及未知的值不输出参数
+//
+// GNU风格下stdcpplatest使用clang和gcc都支持的最新标准名。
+var (
+	cppStandards = map[string]Switch{
+		"stdcpp14":     {"/std:c++14", "-std=c++14"},
+		"stdcpp17":     {"/std:c++17", "-std=c++17"},
+		"stdcpp20":     {"/std:c++20", "-std=c++20"},
+		"stdcpplatest": {"/std:c++latest", "-std=c++2b"},
+	}
+	cStandards = map[string]Switch{
+		"stdc11": {"/std:c11", "-std=c11"},
+		"stdc17": {"/std:c17", "-std=c17"},
+	}
+)
+
+// languageSwitches 返回file的编译语言和语言标准参数
+//
+// GNU风格总是输出-x c或-x c++，因为默认的clang++会把.c文件按C++编译；MSVC风格只在CompileAs
+// 明确指定语言时输出/TC或/TP。C文件只使用LanguageStandard_C，C++文件只使用LanguageStandard。
+func languageSwitches(settings CompileSettings, file string) []Switch {
+	isC := settings.IsC(file)
+	// CompileAsCpp以及C++模块、头单元等都按C++编译
+	lang := Switch{"/TP", "-x c++"}
+	if isC {
+		lang = Switch{"/TC", "-x c"}
+	}
+	if settings.CompileAs == "" {
+		lang.MSVC = ""
+	}
+	switches := []Switch{lang}
+	std, ok := cppStandards[strings.ToLower(settings.LanguageStandard)]
+	if isC {
+		std, ok = cStandards[strings.ToLower(settings.LanguageStandardC)]
+	}
+	if ok {
+		switches = append(switches, std)
+	}
+	return switches
+}
+
+// gnuCppOnlyFlags 只对C++有意义的GNU参数，C文件不输出
+var gnuCppOnlyFlags = map[string]bool{
+	"-fcxx-exceptions": true,
+	"-frtti":           true,
+	"-fno-rtti":        true,
+	"-fno-wchar":       true,
+}
+
+// withoutGNUFlags 从各个设置的GNU参数中去掉flags中的参数，MSVC参数不变
+func withoutGNUFlags(switches []Switch, flags map[string]bool) []Switch {
+	list := make([]Switch, 0, len(switches))
+	for _, sw := range switches {
+		var kept []string
+		for _, f := range strings.Fields(sw.GNU) {
+			if !flags[f] {
+				kept = append(kept, f)
+			}
+		}
+		sw.GNU = strings.Join(kept, " ")
+		list = append(list, sw)
+	}
+	return list
+}
+
+// disabledWarnings 返回DisableSpecificWarnings中的警告编号
+func disabledWarnings(cl *MetadataSet) []string {
+	var list []string
+	for _, v := range strings.Split(cl.Get("DisableSpecificWarnings"), ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// selectSwitches 按转换表选出ClCompile元数据对应的参数
+func (ev *Evaluation) selectSwitches(switches []clSwitch, cl *MetadataSet) []Switch {
+	var list []Switch
+	for _, sw := range switches {
+		value, ok := cl.Lookup(sw.metadata)
+		if !ok && sw.defaultValue != nil {
+			value = sw.defaultValue(ev)
+		}
+		if v, ok := sw.values[strings.ToLower(strings.TrimSpace(value))]; ok {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// msvcToGNU 返回MSVC参数到GNU风格参数的转换表，由上面的各个转换表反向得到
+//
+// GNU风格没有对应参数的MSVC参数映射为空字符串。
+func msvcToGNU() map[string]string {
+	table := map[string]string{"/TC": "-x c", "/TP": "-x c++"}
+	add := func(values map[string]Switch) {
+		for _, v := range values {
+			table[v.MSVC] = v.GNU
+		}
+	}
+	for _, sw := range append(append([]clSwitch(nil), clSwitches...), warningSwitches...) {
+		add(sw.values)
+	}
+	add(cppStandards)
+	add(cStandards)
+	return table
+}
@@ -0,0 +1,142 @@
+package sln
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// hasWildcard 判断项路径中是否包含MSBuild通配符
+func hasWildcard(path string) bool {
+	return strings.ContainsAny(path, "*?")
+}
+
+// expandGlob 按MSBuild规则展开通配符，支持*、?和匹配任意层目录的**
+//
+// 相对路径基于baseDir，返回的结果与pattern保持一致：pattern为相对路径时返回相对baseDir的路径，
+// 否则返回绝对路径。
+func expandGlob(baseDir, pattern string) []string {
+	pattern = nativePath(pattern)
+	relative := !filepath.IsAbs(pattern)
+	absPattern := pattern
+	if relative {
+		absPattern = filepath.Join(baseDir, pattern)
+	}
+	patternSegments := splitPath(absPattern)
+
+	// 从不含通配符的目录前缀开始遍历
+	fixed := 0
+	for fixed < len(patternSegments)-1 && !hasWildcard(patternSegments[fixed]) {
+		fixed++
+	}
+	root := joinSegments(patternSegments[:fixed])
+
+	var matches []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if !matchSegments(patternSegments, splitPath(path)) {
+			return nil
+		}
+		if relative {
+			if rel, err := filepath.Rel(baseDir, path); err == nil {
+				path = rel
+			}
+		}
+		matches = append(matches, path)
+		return nil
+	})
+	return matches
+}
+
+// matchItemSpec 判断path是否匹配任意一个项规格，规格可以包含通配符，相对路径基于baseDir
+func matchItemSpec(baseDir, path string, specs []string) bool {
+	pathSegments := splitPath(absItemPath(baseDir, path))
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if matchSegments(splitPath(absItemPath(baseDir, spec)), pathSegments) {
+			return true
+		}
+	}
+	return false
+}
+
+func absItemPath(baseDir, path string) string {
+	path = nativePath(strings.TrimSpace(path))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// splitPath 把清理后的绝对路径拆分为各级名称，第一段为卷名或空字符串（根目录）
+func splitPath(path string) []string {
+	return strings.Split(filepath.Clean(path), string(filepath.Separator))
+}
+
+func joinSegments(segments []string) string {
+	path := strings.Join(segments, string(filepath.Separator))
+	if path == "" || strings.HasSuffix(path, ":") {
+		path += string(filepath.Separator)
+	}
+	return path
+}
+
+// matchSegments 逐级匹配路径，**匹配零到多级目录
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 || !matchWildcard(pattern[0], name[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// matchWildcard 匹配单级名称，*匹配任意个字符，?匹配单个字符，不区分大小写
+func matchWildcard(pattern, name string) bool {
+	pattern = strings.ToLower(pattern)
+	name = strings.ToLower(name)
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchWildcard(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if name == "" {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(name)
+			pattern = pattern[1:]
+			name = name[size:]
+		default:
+			if name == "" || pattern[0] != name[0] {
+				return false
+			}
+			pattern = pattern[1:]
+			name = name[1:]
+		}
+	}
+	return name == ""
+}
@@ -0,0 +1,183 @@
+package sln
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.cpp", "main.cpp", true},
+		{"*.cpp", "main.c", false},
+		{"*.CPP", "Main.cpp", true},
+		{"*", "", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"?.c", "a.c", true},
+		{"?.c", "ab.c", false},
+		{"?.c", ".c", false},
+		{"??.c", "中文.c", true},
+		{"file?.*", "file1.h", true},
+		{"main.cpp", "main.cpp", true},
+		{"main.cpp", "main.cppx", false},
+	}
+	for _, tt := range tests {
+		if got := matchWildcard(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchWildcard(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern, name []string
+		want          bool
+	}{
+		{[]string{"src", "**", "*.cpp"}, []string{"src", "a.cpp"}, true},
+		{[]string{"src", "**", "*.cpp"}, []string{"src", "x", "y", "a.cpp"}, true},
+		{[]string{"src", "**", "*.cpp"}, []string{"lib", "a.cpp"}, false},
+		{[]string{"src", "**"}, []string{"src", "x", "a.h"}, true},
+		{[]string{"**", "test", "*.cpp"}, []string{"a", "test", "b.cpp"}, true},
+		{[]string{"**", "test", "*.cpp"}, []string{"a", "tests", "b.cpp"}, false},
+		{[]string{"src", "?", "*.c"}, []string{"src", "x", "a.c"}, true},
+		{[]string{"src", "*.c"}, []string{"src", "x", "a.c"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchSegments(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+// writeFiles 在dir下创建files中的空文件，路径使用/分隔
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpandGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir,
+		"src/main.cpp",
+		"src/util.c",
+		"src/a/b/deep.cpp",
+		"src/a/x1.cpp",
+		"src/a/x22.cpp",
+		"include/main.h",
+	)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`src\*.cpp`, []string{"src/main.cpp"}},
+		{`src\**\*.cpp`, []string{"src/a/b/deep.cpp", "src/a/x1.cpp", "src/a/x22.cpp", "src/main.cpp"}},
+		{`**\*.h`, []string{"include/main.h"}},
+		{`src\a\x?.cpp`, []string{"src/a/x1.cpp"}},
+		{`src\*\*.cpp`, []string{"src/a/x1.cpp", "src/a/x22.cpp"}},
+		{`src\**`, []string{"src/a/b/deep.cpp", "src/a/x1.cpp", "src/a/x22.cpp", "src/main.cpp", "src/util.c"}},
+		{`missing\**\*.cpp`, nil},
+	}
+	for _, tt := range tests {
+		got := expandGlob(dir, tt.pattern)
+		sort.Strings(got)
+		var want []string
+		for _, v := range tt.want {
+			want = append(want, filepath.FromSlash(v))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expandGlob(%q) = %q, want %q", tt.pattern, got, want)
+		}
+	}
+
+	// 绝对路径的模式返回绝对路径
+	got := expandGlob(dir, filepath.Join(dir, "src", "*.c"))
+	if want := []string{filepath.Join(dir, "src", "util.c")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expandGlob(absolute) = %q, want %q", got, want)
+	}
+}
+
+func TestMatchItemSpec(t *testing.T) {
+	base := filepath.FromSlash("/project")
+	tests := []struct {
+		path  string
+		specs []string
+		want  bool
+	}{
+		{`src\main.cpp`, []string{`src\main.cpp`}, true},
+		{`src\main.cpp`, []string{`SRC\Main.cpp`}, true},
+		{`src\main.cpp`, []string{`src\*.c`}, false},
+		{`src\gen\a.cpp`, []string{`src\**\gen\*`}, true},
+		{`src\gen\a.cpp`, []string{`other.cpp`, ` `, `**\a.cpp`}, true},
+		{`src\a.cpp`, []string{filepath.Join(base, "src", "a.cpp")}, true},
+		{`src\a.cpp`, nil, false},
+	}
+	for _, tt := range tests {
+		if got := matchItemSpec(base, tt.path, tt.specs); got != tt.want {
+			t.Errorf("matchItemSpec(%q, %q) = %v, want %v", tt.path, tt.specs, got, tt.want)
+		}
+	}
+}
+
+func TestItemsExclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir,
+		"src/main.cpp",
+		"src/old/legacy.cpp",
+		"src/gen/a.cpp",
+		"src/gen/b.cpp",
+		"src/test_main.cpp",
+	)
+	project := `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemGroup Label="ProjectConfigurations">
+    <ProjectConfiguration Include="Debug|x64" />
+  </ItemGroup>
+  <ItemGroup>
+    <ClCompile Include="src\**\*.cpp" Exclude="src\old\**;src\test_?ain.cpp" />
+    <ClCompile Remove="src\gen\b.cpp" />
+  </ItemGroup>
+</Project>`
+	path := filepath.Join(dir, "glob.vcxproj")
+	if err := ioutil.WriteFile(path, []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pro, err := NewProject(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pro.Options.NoDirectoryBuild = true
+	got, err := pro.FindSourceFiles("Debug|x64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{filepath.FromSlash("src/gen/a.cpp"), filepath.FromSlash("src/main.cpp")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSourceFiles() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,204 @@
+package sln
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DependencyGraph 解决方案中项目之间的依赖关系
+//
+// 依赖来自项目文件中的ProjectReference以及解决方案中声明的生成依赖
+// （.sln的ProjectDependencies、.slnx的BuildDependency）。
+type DependencyGraph struct {
+	// 按解决方案中的顺序排列的项目
+	Projects []*Project
+
+	// 项目直接依赖的项目
+	dependencies map[*Project][]*Project
+	// 直接依赖该项目的项目
+	dependents map[*Project][]*Project
+}
+
+// DependencyCycleError 项目之间存在循环依赖
+type DependencyCycleError struct {
+	// 构成循环的项目，首尾为同一个项目
+	Cycle []*Project
+}
+
+func (e *DependencyCycleError) Error() string {
+	var names []string
+	for _, pro := range e.Cycle {
+		names = append(names, pro.Name())
+	}
+	return "project dependency cycle: " + strings.Join(names, " -> ")
+}
+
+// Name 返回项目名，优先使用解决方案中的名称
+func (pro *Project) Name() string {
+	if pro.SolutionProject != nil && pro.SolutionProject.Name != "" {
+		return pro.SolutionProject.Name
+	}
+	name := filepath.Base(pro.ProjectPath)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// DependencyGraph 在解决方案配置conf下构建项目依赖图
+//
+// 每个项目按解决方案中的映射确定自己的配置后求值ProjectReference，
+// 因此带条件的引用只在条件成立的配置下计入；引用了解决方案之外的项目时输出警告并忽略。
+func (sln *Sln) DependencyGraph(conf string) (*DependencyGraph, error) {
+	g := &DependencyGraph{
+		dependencies: map[*Project][]*Project{},
+		dependents:   map[*Project][]*Project{},
+	}
+	byPath := map[string]*Project{}
+	byGUID := map[string]*Project{}
+	for i := range sln.ProjectList {
+		pro := &sln.ProjectList[i]
+		g.Projects = append(g.Projects, pro)
+		byPath[pathKey(pro.ProjectPath)] = pro
+		if pro.SolutionProject != nil && pro.SolutionProject.GUID != "" {
+			byGUID[pro.SolutionProject.GUID] = pro
+		}
+	}
+
+	for _, pro := range g.Projects {
+		proConf, _ := pro.projectConfiguration(conf)
+		ev, err := pro.Evaluate(proConf)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range ev.Items("ProjectReference") {
+			path := ev.GetMetadata(ref, "FullPath")
+			dep := byPath[pathKey(path)]
+			if dep == nil {
+				// Project元数据记录被引用项目的GUID
+				dep = byGUID[strings.ToUpper(strings.TrimSpace(ev.GetMetadata(ref, "Project")))]
+			}
+			if dep == nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: referenced project %s is not in the solution, ignored\n",
+					pro.ProjectPath, path)
+				continue
+			}
+			g.addEdge(pro, dep)
+		}
+
+		if pro.SolutionProject == nil {
+			continue
+		}
+		for _, v := range pro.SolutionProject.Dependencies {
+			var dep *Project
+			if strings.HasPrefix(v, "{") {
+				dep = byGUID[strings.ToUpper(v)]
+			} else {
+				dep = byPath[pathKey(filepath.Join(sln.SolutionDir, nativePath(v)))]
+			}
+			// 解决方案中的依赖可能指向未加载的非VC++项目，直接忽略
+			if dep != nil {
+				g.addEdge(pro, dep)
+			}
+		}
+	}
+	return g, nil
+}
+
+// addEdge 记录from依赖to，忽略重复的依赖
+func (g *DependencyGraph) addEdge(from, to *Project) {
+	for _, v := range g.dependencies[from] {
+		if v == to {
+			return
+		}
+	}
+	g.dependencies[from] = append(g.dependencies[from], to)
+	g.dependents[to] = append(g.dependents[to], from)
+}
+
+// Dependencies 返回pro直接依赖的项目
+func (g *DependencyGraph) Dependencies(pro *Project) []*Project {
+	return g.dependencies[pro]
+}
+
+// Dependents 返回直接依赖pro的项目
+func (g *DependencyGraph) Dependents(pro *Project) []*Project {
+	return g.dependents[pro]
+}
+
+// TransitiveDependencies 返回pro直接或间接依赖的所有项目
+func (g *DependencyGraph) TransitiveDependencies(pro *Project) []*Project {
+	return g.reachable(pro, g.dependencies)
+}
+
+// TransitiveDependents 返回直接或间接依赖pro的所有项目，即修改pro会影响到的项目
+func (g *DependencyGraph) TransitiveDependents(pro *Project) []*Project {
+	return g.reachable(pro, g.dependents)
+}
+
+// reachable 按广度优先顺序返回从pro出发沿edges可以到达的项目，不包括pro本身
+func (g *DependencyGraph) reachable(pro *Project, edges map[*Project][]*Project) []*Project {
+	visited := map[*Project]bool{pro: true}
+	var list []*Project
+	queue := []*Project{pro}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[cur] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			list = append(list, next)
+			queue = append(queue, next)
+		}
+	}
+	return list
+}
+
+// TopologicalOrder 返回依赖在前的项目顺序，没有依赖关系的项目保持解决方案中的顺序
+//
+// 存在循环依赖时返回*DependencyCycleError。
+func (g *DependencyGraph) TopologicalOrder() ([]*Project, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[*Project]int{}
+	var order []*Project
+	var stack []*Project
+
+	var visit func(pro *Project) error
+	visit = func(pro *Project) error {
+		switch state[pro] {
+		case done:
+			return nil
+		case visiting:
+			// 从栈中第一次出现pro的位置截取出循环
+			for i, v := range stack {
+				if v == pro {
+					cycle := append([]*Project{}, stack[i:]...)
+					return &DependencyCycleError{Cycle: append(cycle, pro)}
+				}
+			}
+		}
+		state[pro] = visiting
+		stack = append(stack, pro)
+		for _, dep := range g.dependencies[pro] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[pro] = done
+		order = append(order, pro)
+		return nil
+	}
+
+	for _, pro := range g.Projects {
+		if err := visit(pro); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,108 @@
+package sln
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// MetadataSet 项的元数据，元数据名不区分大小写，按首次定义的顺序保存
+type MetadataSet struct {
+	names  []string
+	values map[string]string
+}
+
+// NewMetadataSet 创建空的元数据集
+func NewMetadataSet() *MetadataSet {
+	return &MetadataSet{values: map[string]string{}}
+}
+
+// Set 设置元数据值，后定义的值覆盖先定义的值
+func (ms *MetadataSet) Set(name, value string) {
+	key := strings.ToLower(name)
+	if _, ok := ms.values[key]; !ok {
+		ms.names = append(ms.names, name)
+	}
+	ms.values[key] = value
+}
+
+// Lookup 查找元数据值，找不到时第二个返回值为false
+func (ms *MetadataSet) Lookup(name string) (string, bool) {
+	v, ok := ms.values[strings.ToLower(name)]
+	return v, ok
+}
+
+// Get 获取元数据值，未定义的元数据返回空字符串
+func (ms *MetadataSet) Get(name string) string {
+	return ms.values[strings.ToLower(name)]
+}
+
+// Names 按首次定义的顺序返回所有元数据名
+func (ms *MetadataSet) Names() []string {
+	return append([]string(nil), ms.names...)
+}
+
+// Clone 返回元数据集的副本
+func (ms *MetadataSet) Clone() *MetadataSet {
+	c := &MetadataSet{
+		names:  append([]string(nil), ms.names...),
+		values: make(map[string]string, len(ms.values)),
+	}
+	for k, v := range ms.values {
+		c.values[k] = v
+	}
+	return c
+}
+
+// EvaluatedItem 当前配置下生效的一个项
+type EvaluatedItem struct {
+	// 项类型，即项元素名，例如ClCompile
+	Type string
+	// 展开属性引用和通配符之后的项规格，通常为相对项目目录的文件路径
+	Include string
+	// 合并ItemDefinitionGroup默认值之后的元数据
+	Metadata *MetadataSet
+}
+
+// GetProperty 返回属性在当前配置下的值，未定义的属性返回空字符串
+func (ev *Evaluation) GetProperty(name string) string {
+	return ev.Properties.Get(name)
+}
+
+// GetMetadata 返回项在当前配置下的元数据值，未定义的元数据返回空字符串
+//
+// 除了项目文件中定义的元数据，还支持Identity、FullPath、RootDir、Filename、
+// Extension、RelativeDir和Directory等MSBuild内置元数据。
+func (ev *Evaluation) GetMetadata(item EvaluatedItem, name string) string {
+	if v, ok := item.Metadata.Lookup(name); ok {
+		return v
+	}
+	fullPath := absItemPath(ev.Project.ProjectDir, item.Include)
+	switch strings.ToLower(name) {
+	case "identity":
+		return item.Include
+	case "fullpath":
+		return fullPath
+	case "rootdir":
+		return withTrailingSeparator(filepath.VolumeName(fullPath) + string(filepath.Separator))
+	case "filename":
+		return strings.TrimSuffix(filepath.Base(fullPath), filepath.Ext(fullPath))
+	case "extension":
+		return filepath.Ext(fullPath)
+	case "relativedir":
+		dir := filepath.Dir(nativePath(strings.TrimSpace(item.Include)))
+		if dir == "." {
+			return ""
+		}
+		return withTrailingSeparator(dir)
+	case "directory":
+		dir := filepath.Dir(fullPath)
+		dir = strings.TrimPrefix(dir, filepath.VolumeName(dir))
+		return withTrailingSeparator(strings.TrimPrefix(dir, string(filepath.Separator)))
+	}
+	return ""
+}
+
+// isTrue 判断元数据或属性的值是否为true
+func isTrue(value string) bool {
+	return strings.EqualFold(strings.TrimSpace(value), "true")
+}
@@ -0,0 +1,136 @@
+package sln
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExcludedPolicy 处理ExcludedFromBuild文件的方式
+type ExcludedPolicy string
+
+const (
+	// ExcludedDrop 不输出被排除的文件
+	ExcludedDrop ExcludedPolicy = "drop"
+	// ExcludedMark 输出被排除的文件，并在条目中添加"excluded": true
+	//
+	// clang的compile_commands.json解析器不接受未知字段，clangd和clang-tidy会拒绝整个文件，该模式只供自定义工具使用。
+	ExcludedMark ExcludedPolicy = "mark"
+)
+
+// SharedPolicy 同一个源文件出现在多个项目中（例如.vcxitems共享项目）时的处理方式
+type SharedPolicy string
+
+const (
+	// SharedAll 每个包含该文件的项目各输出一个条目
+	SharedAll SharedPolicy = "all"
+	// SharedFirst 只输出解决方案中第一个包含该文件的项目的条目
+	SharedFirst SharedPolicy = "first"
+	// sharedPreferPrefix "prefer:项目名或项目文件路径"优先使用指定项目的条目，该项目不包含此文件时同SharedFirst
+	sharedPreferPrefix = "prefer:"
+)
+
+// PchPolicy 使用预编译头（PrecompiledHeader为Create或Use）的文件的处理方式
+type PchPolicy string
+
+const (
+	// PchForceInclude 使用预编译头的文件强制包含PrecompiledHeaderFile，
+	// 与/Yu隐式包含该头文件的效果相同，clangd不需要预编译头本身
+	PchForceInclude PchPolicy = "force-include"
+	// PchMsvc 输出cl.exe实际看到的/Yc、/Yu和/Fp参数
+	PchMsvc PchPolicy = "msvc"
+	// PchStrip 不输出任何预编译头相关的参数
+	PchStrip PchPolicy = "strip"
+)
+
+// DriverKind 生成编译命令使用的编译器驱动，取值为RegisterDriver注册的名称
+type DriverKind string
+
+const (
+	// DriverClangCl clang-cl，使用MSVC风格的参数
+	DriverClangCl DriverKind = "clang-cl"
+	// DriverGNU clang++、g++等GNU风格的驱动
+	DriverGNU DriverKind = "gnu"
+	// DriverCl MSVC的cl.exe
+	DriverCl DriverKind = "cl"
+)
+
+// Options 控制项目求值和编译命令生成的行为，零值即默认行为
+type Options struct {
+	// 不自动导入Directory.Build.props和Directory.Build.targets
+	NoDirectoryBuild bool
+	// 处理ExcludedFromBuild文件的方式，默认为ExcludedDrop
+	Excluded ExcludedPolicy
+	// 多个项目共享的源文件的处理方式，默认为SharedAll
+	Shared SharedPolicy
+	// 预编译头的处理方式，默认为PchForceInclude
+	Pch PchPolicy
+	// 不输出WarningLevel、TreatWarningAsError等警告相关的参数
+	NoWarningFlags bool
+	// 编译器驱动，默认为DriverClangCl
+	Driver DriverKind
+	// 编译器可执行文件的路径，为空时使用驱动的默认值，例如clang-cl.exe
+	DriverPath string
+}
+
+// ParseExcludedPolicy 解析命令行中的排除文件处理方式
+func ParseExcludedPolicy(s string) (ExcludedPolicy, error) {
+	switch p := ExcludedPolicy(s); p {
+	case "", ExcludedDrop:
+		return ExcludedDrop, nil
+	case ExcludedMark:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown excluded file policy %q, expected drop or mark", s)
+}
+
+// ParseSharedPolicy 解析命令行中的共享文件处理方式：all、first或prefer:项目名（或项目文件路径）
+func ParseSharedPolicy(s string) (SharedPolicy, error) {
+	switch p := SharedPolicy(s); p {
+	case "", SharedAll:
+		return SharedAll, nil
+	case SharedFirst:
+		return p, nil
+	}
+	if strings.HasPrefix(s, sharedPreferPrefix) && strings.TrimSpace(s[len(sharedPreferPrefix):]) != "" {
+		return SharedPolicy(s), nil
+	}
+	return "", fmt.Errorf("unknown shared file policy %q, expected all, first or prefer:<project>", s)
+}
+
+// ParsePchPolicy 解析命令行中的预编译头处理方式
+func ParsePchPolicy(s string) (PchPolicy, error) {
+	switch p := PchPolicy(s); p {
+	case "", PchForceInclude:
+		return PchForceInclude, nil
+	case PchMsvc, PchStrip:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown precompiled header policy %q, expected force-include, msvc or strip", s)
+}
+
+// ParseDriverKind 解析命令行中的编译器驱动
+func ParseDriverKind(s string) (DriverKind, error) {
+	if s == "" {
+		return DriverClangCl, nil
+	}
+	if _, ok := drivers[DriverKind(s)]; ok {
+		return DriverKind(s), nil
+	}
+	return "", fmt.Errorf("unknown compiler driver %q, expected %s", s, strings.Join(driverNames(), ", "))
+}
+
+// preferredProject 返回prefer:策略指定的项目名或项目文件路径，其他策略返回空字符串
+func (p SharedPolicy) preferredProject() string {
+	if !strings.HasPrefix(string(p), sharedPreferPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(string(p)[len(sharedPreferPrefix):])
+}
+
+// SetOptions 设置解决方案及其中所有项目的选项
+func (sln *Sln) SetOptions(opts Options) {
+	sln.Options = opts
+	for i := range sln.ProjectList {
+		sln.ProjectList[i].Options = opts
+	}
+}
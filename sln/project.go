@@ -7,63 +7,100 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
 type Project struct {
 	ProjectDir          string
 	ProjectPath         string
+	SolutionDir         string
+	Options             Options
+	SolutionProject     *SolutionProject      // 项目在解决方案中的条目，直接加载项目文件时为nil
 	XMlName             xml.Name              `xml:"Project"`
 	PropertyGroup       []PropertyGroup       `xml:"PropertyGroup"`
 	Import              []Import              `xml:"Import"`
+	ImportGroup         []ImportGroup         `xml:"ImportGroup"`
 	ItemGroup           []ItemGroup           `xml:"ItemGroup"`
 	ItemDefinitionGroup []ItemDefinitionGroup `xml:"ItemDefinitionGroup"`
+	Choose              []Choose              `xml:"Choose"`
+
+	// 顶层元素的文档顺序，求值时按此顺序处理
+	elements []elementRef
+	// 已解析的导入文件，按绝对路径缓存
+	imports map[string]*Project
 }
 
-// 通用的ClCompile元素结构
-type ClCompile struct {
-	XMLName                      xml.Name `xml:"ClCompile"`
-	Include                      string   `xml:"Include,attr"`
-	AdditionalIncludeDirectories string   `xml:"AdditionalIncludeDirectories"`
-	PreprocessorDefinitions      string   `xml:"PreprocessorDefinitions"`
-	AdditionalOptions            string   `xml:"AdditionalOptions"`
+// 顶层元素的类型
+type elementKind int
+
+const (
+	elementPropertyGroup elementKind = iota
+	elementImport
+	elementImportGroup
+	elementItemGroup
+	elementItemDefinitionGroup
+	elementChoose
+)
+
+// elementRef 记录一个顶层元素在对应切片中的下标
+type elementRef struct {
+	kind  elementKind
+	index int
 }
 
-type ItemGroup struct {
-	XMLName                  xml.Name               `xml:"ItemGroup"`
-	Label                    string                 `xml:"Label,attr"`
-	ProjectConfigurationList []ProjectConfiguration `xml:"ProjectConfiguration"`
-	// 合并两个字段为一个通用的ClCompile列表
-	ClCompileList []ClCompile `xml:"ClCompile"`
+// Item ItemGroup中的一个项，元素名即项类型，例如ClCompile、ProjectReference和ProjectConfiguration
+//
+// 子元素全部作为元数据按文档顺序保存，不限定元数据名。
+type Item struct {
+	XMLName   xml.Name
+	Include   string     `xml:"Include,attr"`
+	Exclude   string     `xml:"Exclude,attr"`
+	Remove    string     `xml:"Remove,attr"`
+	Update    string     `xml:"Update,attr"`
+	Condition string     `xml:"Condition,attr"`
+	Metadata  []Metadata `xml:",any"`
 }
 
-type ProjectConfiguration struct {
-	XMLName       xml.Name `xml:"ProjectConfiguration"`
-	Include       string   `xml:"Include,attr"`
-	Configuration string   `xml:"Configuration"`
-	Platform      string   `xml:"Platform"`
+// Type 返回项类型
+func (it Item) Type() string {
+	return it.XMLName.Local
+}
+
+// 项的单个元数据，元素名即元数据名
+type Metadata struct {
+	XMLName   xml.Name
+	Condition string `xml:"Condition,attr"`
+	Value     string `xml:",chardata"`
+}
+
+// Name 返回元数据名
+func (m Metadata) Name() string {
+	return m.XMLName.Local
+}
+
+type ItemGroup struct {
+	XMLName   xml.Name `xml:"ItemGroup"`
+	Label     string   `xml:"Label,attr"`
+	Condition string   `xml:"Condition,attr"`
+	Items     []Item   `xml:",any"`
 }
 
 type ItemDefinitionGroup struct {
-	XMLName   xml.Name     `xml:"ItemDefinitionGroup"`
-	Condition string       `xml:"Condition,attr"`
-	ClCompile ClCompileDef `xml:"ClCompile"`
-}
-
-// ItemDefinitionGroup中的ClCompile元素
-type ClCompileDef struct {
-	XMLName                      xml.Name `xml:"ClCompile"`
-	AdditionalIncludeDirectories string   `xml:"AdditionalIncludeDirectories"`
-	PreprocessorDefinitions      string   `xml:"PreprocessorDefinitions"`
-	// 添加更多编译选项支持
-	AdditionalOptions string `xml:"AdditionalOptions"`
-	WarningLevel      string `xml:"WarningLevel"`
-	Optimization      string `xml:"Optimization"`
-	RuntimeLibrary    string `xml:"RuntimeLibrary"`
-	LanguageStandard  string `xml:"LanguageStandard"`
-	// 支持Conan等包管理器的include路径
-	AdditionalUsingDirectories string `xml:"AdditionalUsingDirectories"`
+	XMLName     xml.Name         `xml:"ItemDefinitionGroup"`
+	Condition   string           `xml:"Condition,attr"`
+	Definitions []ItemDefinition `xml:",any"`
+}
+
+// ItemDefinition ItemDefinitionGroup中某种项类型的默认元数据，元素名即项类型
+type ItemDefinition struct {
+	XMLName   xml.Name
+	Condition string     `xml:"Condition,attr"`
+	Metadata  []Metadata `xml:",any"`
+}
+
+// Type 返回项类型
+func (d ItemDefinition) Type() string {
+	return d.XMLName.Local
 }
 
 // 移除重复的ClCompileSrc结构体，使用统一的ClCompile结构
@@ -72,348 +109,378 @@ type CompileCommand struct {
 	Dir  string `json:"directory"`
 	Cmd  string `json:"command"`
 	File string `json:"file"`
+	// 仅在ExcludedMark模式下标记被ExcludedFromBuild排除的文件
+	Excluded bool `json:"excluded,omitempty"`
 }
 
-var (
-	badInclude = []string{
-		";%(AdditionalIncludeDirectories)",
-		"%(AdditionalIncludeDirectories);",
+func NewProject(path string) (Project, error) {
+	parse := parseProjectFile
+	if strings.EqualFold(filepath.Ext(path), ".vcproj") {
+		parse = parseVcprojFile
 	}
-	badDef = []string{
-		";%(PreprocessorDefinitions)",
-		"%(PreprocessorDefinitions);",
+	pro, err := parse(path)
+	if err != nil {
+		return Project{}, err
 	}
-	badOpts = []string{"%(AdditionalOptions)"}
-)
+	pro.imports = map[string]*Project{}
+	return *pro, nil
+}
 
-func NewProject(path string) (Project, error) {
+// parseProjectFile 解析MSBuild格式的文件，.vcxproj、.props和.targets的结构相同
+func parseProjectFile(path string) (*Project, error) {
 	var pro Project
 	var err error
 
 	pro.ProjectPath, err = filepath.Abs(path)
 	if err != nil {
-		return pro, err
+		return nil, err
 	}
 	pro.ProjectDir = filepath.Dir(pro.ProjectPath)
 
 	f, err := os.Open(path)
 	if err != nil {
-		return Project{}, err
+		return nil, err
 	}
 	defer f.Close()
 	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
 	err = xml.Unmarshal([]byte(data), &pro)
 	if err != nil {
-		return pro, err
+		return nil, fmt.Errorf("%s: %v", pro.ProjectPath, err)
 	}
-	return pro, nil
+	return &pro, nil
 }
 
-// 增强的配置查找函数，返回更完整的编译信息
-func (pro *Project) FindConfigEnhanced(conf string) (string, string, string, string, error) {
-	var cfgList []ProjectConfiguration
-	for _, v := range pro.ItemGroup {
-		if len(v.ProjectConfigurationList) > 0 {
-			cfgList = v.ProjectConfigurationList
-			break
+// UnmarshalXML 解析Project元素，同时记录顶层元素的文档顺序
+func (pro *Project) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	pro.XMlName = start.Name
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := pro.decodeElement(d, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
 		}
 	}
+}
 
-	// 收集所有可用配置
-	var availableConfigs []string
-	for _, v := range cfgList {
-		availableConfigs = append(availableConfigs, v.Include)
+func (pro *Project) decodeElement(d *xml.Decoder, start xml.StartElement) error {
+	var err error
+	var ref elementRef
+	switch start.Name.Local {
+	case "PropertyGroup":
+		var v PropertyGroup
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementPropertyGroup, len(pro.PropertyGroup)}
+		pro.PropertyGroup = append(pro.PropertyGroup, v)
+	case "Import":
+		var v Import
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementImport, len(pro.Import)}
+		pro.Import = append(pro.Import, v)
+	case "ImportGroup":
+		var v ImportGroup
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementImportGroup, len(pro.ImportGroup)}
+		pro.ImportGroup = append(pro.ImportGroup, v)
+	case "ItemGroup":
+		var v ItemGroup
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementItemGroup, len(pro.ItemGroup)}
+		pro.ItemGroup = append(pro.ItemGroup, v)
+	case "ItemDefinitionGroup":
+		var v ItemDefinitionGroup
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementItemDefinitionGroup, len(pro.ItemDefinitionGroup)}
+		pro.ItemDefinitionGroup = append(pro.ItemDefinitionGroup, v)
+	case "Choose":
+		var v Choose
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementChoose, len(pro.Choose)}
+		pro.Choose = append(pro.Choose, v)
+	default:
+		// Target等求值无关的元素直接跳过
+		return d.Skip()
 	}
-
-	// 查找完全匹配的配置
-	found := false
-	matchedConfig := conf
-	for _, v := range cfgList {
-		if v.Include == conf {
-			matchedConfig = v.Include
-			found = true
-			break
-		}
+	if err != nil {
+		return err
 	}
+	pro.elements = append(pro.elements, ref)
+	return nil
+}
 
-	// 如果完全匹配失败，尝试查找相同平台的其他配置
-	if !found {
-		// 解析用户请求的配置和平台
-		requestedParts := strings.Split(conf, "|")
-		if len(requestedParts) == 2 {
-			requestedPlatform := requestedParts[1]
-
-			// 查找相同平台的配置
-			for _, v := range cfgList {
-				configParts := strings.Split(v.Include, "|")
-				if len(configParts) == 2 && configParts[1] == requestedPlatform {
-					matchedConfig = v.Include
-					found = true
-					fmt.Fprintf(os.Stderr, "Warning: Configuration %s not found, using %s instead\n", conf, matchedConfig)
-					break
-				}
-			}
-		}
+// 增强的配置查找函数，返回更完整的编译信息
+func (pro *Project) FindConfigEnhanced(conf string) (string, string, string, string, error) {
+	ev, err := pro.Evaluate(conf)
+	if err != nil {
+		return "", "", "", "", err
 	}
+	s := ev.findConfigEnhanced()
+	return s.IncludeDirs, s.Definitions, s.AdditionalOptions, s.UsingDirs, nil
+}
 
-	// 如果仍然没有找到匹配的配置，返回错误并列出可用配置
-	if !found {
-		return "", "", "", "", fmt.Errorf("%s:not found %s\nAvailable configurations: %v", pro.ProjectPath, conf, availableConfigs)
-	}
-
-	// 解析配置和平台
-	vlist := strings.Split(matchedConfig, "|")
-	configuration := vlist[0]
-	platform := vlist[1]
-
-	// 构建环境变量替换映射
-	willReplaceEnv := map[string]string{
-		"$(ProjectDir)":        pro.ProjectDir,
-		"$(Configuration)":     configuration,
-		"$(ConfigurationName)": configuration,
-		"$(Platform)":          platform,
-	}
-	for _, v := range os.Environ() {
-		kv := strings.SplitN(v, "=", 2)
-		if len(kv) == 2 {
-			willReplaceEnv[fmt.Sprintf("$(%s)", kv[0])] = kv[1]
-		}
-	}
+// findConfigEnhanced 返回项目级的编译设置
+func (ev *Evaluation) findConfigEnhanced() CompileSettings {
+	cl, _ := ev.definition("ClCompile")
+	return ev.compileSettings(cl)
+}
 
-	// 从PropertyGroup中收集include目录
-	propertyIncludeDirs := []string{}
-	for _, v := range pro.PropertyGroup {
-		// 匹配条件
-		if v.Condition == "" || strings.Contains(v.Condition, matchedConfig) {
-			if v.AdditionalIncludeDirectories != "" {
-				propertyIncludeDirs = append(propertyIncludeDirs, v.AdditionalIncludeDirectories)
-			}
-			if v.IncludeDirectories != "" {
-				propertyIncludeDirs = append(propertyIncludeDirs, v.IncludeDirectories)
-			}
-		}
-	}
+// CompileSettings 源文件在当前配置下的编译设置，列表均以分号分隔
+type CompileSettings struct {
+	// AdditionalIncludeDirectories和VC++目录中的IncludePath
+	IncludeDirs string
+	// VC++目录中的ExternalIncludePath，作为外部头文件目录，不报告其中的警告
+	ExternalIncludeDirs string
+	Definitions         string
+	AdditionalOptions   string
+	UsingDirs           string
+	// ForcedIncludeFiles中强制包含的头文件
+	ForcedIncludes string
+	// 预编译头的用法：Create、Use，不使用预编译头时为空
+	PrecompiledHeader string
+	// 预编译头对应的头文件，即#include中的写法，默认为stdafx.h
+	PrecompiledHeaderFile string
+	// 预编译头(.pch)文件的路径
+	PrecompiledHeaderOutputFile string
+	// C++语言标准，例如stdcpp17，默认为空
+	LanguageStandard string
+	// C语言标准，例如stdc11，默认为空
+	LanguageStandardC string
+	// 编译语言：CompileAsC、CompileAsCpp等，为空时按源文件扩展名确定
+	CompileAs string
+}
 
-	// 从ItemDefinitionGroup中收集配置
-	var include string
-	var def string
-	var additionalOpts string
-	var usingDirs string
-
-	for _, v := range pro.ItemDefinitionGroup {
-		// 使用匹配的配置而不是原始请求的配置
-		if strings.Contains(v.Condition, matchedConfig) {
-			cl := v.ClCompile
-			include = cl.AdditionalIncludeDirectories
-			def = cl.PreprocessorDefinitions
-			additionalOpts = cl.AdditionalOptions
-			usingDirs = cl.AdditionalUsingDirectories
-			break
-		}
-	}
+// compileSettings 把ClCompile元数据与VC++目录合并为源文件的编译设置
+func (ev *Evaluation) compileSettings(cl *MetadataSet) CompileSettings {
+	dirs := ev.VCDirectories()
+	settings := CompileSettings{
+		// 与cl.exe一致，AdditionalIncludeDirectories先于INCLUDE环境变量（即IncludePath）搜索
+		IncludeDirs: MergeSemicolonSeparatedLists(ev.Properties.Get("AdditionalIncludeDirectories"),
+			cl.Get("AdditionalIncludeDirectories"), dirs.IncludePath),
+		ExternalIncludeDirs: MergeSemicolonSeparatedLists(dirs.ExternalIncludePath),
+		Definitions:         cl.Get("PreprocessorDefinitions"),
+		AdditionalOptions:   cl.Get("AdditionalOptions"),
+		UsingDirs:           cl.Get("AdditionalUsingDirectories"),
+		ForcedIncludes:      ev.forcedIncludes(cl.Get("ForcedIncludeFiles")),
+		LanguageStandard:    strings.TrimSpace(cl.Get("LanguageStandard")),
+		LanguageStandardC:   strings.TrimSpace(cl.Get("LanguageStandard_C")),
+		CompileAs:           strings.TrimSpace(cl.Get("CompileAs")),
+	}
+	if strings.EqualFold(settings.CompileAs, "Default") {
+		settings.CompileAs = ""
+	}
+
+	switch pch := strings.TrimSpace(cl.Get("PrecompiledHeader")); {
+	case strings.EqualFold(pch, "Create"):
+		settings.PrecompiledHeader = "Create"
+	case strings.EqualFold(pch, "Use"):
+		settings.PrecompiledHeader = "Use"
+	default:
+		// NotUsing或未设置
+		return settings
+	}
+	settings.PrecompiledHeaderFile = strings.TrimSpace(cl.Get("PrecompiledHeaderFile"))
+	if settings.PrecompiledHeaderFile == "" {
+		settings.PrecompiledHeaderFile = "stdafx.h"
+	}
+	settings.PrecompiledHeaderOutputFile = strings.TrimSpace(cl.Get("PrecompiledHeaderOutputFile"))
+	return settings
+}
 
-	// 合并PropertyGroup和ItemDefinitionGroup中的include目录
-	if len(propertyIncludeDirs) > 0 {
-		if include != "" {
-			propertyIncludeDirs = append(propertyIncludeDirs, include)
-			include = MergeSemicolonSeparatedLists(propertyIncludeDirs...)
-		} else {
-			include = MergeSemicolonSeparatedLists(propertyIncludeDirs...)
-		}
+// IsC 判断file是否按C语言编译：CompileAs为CompileAsC，或者未指定时扩展名为.c
+func (s CompileSettings) IsC(file string) bool {
+	if s.CompileAs != "" {
+		return strings.EqualFold(s.CompileAs, "CompileAsC")
 	}
+	return strings.EqualFold(filepath.Ext(nativePath(file)), ".c")
+}
 
-	// 处理所有字段的环境变量替换
-	for k, v := range willReplaceEnv {
-		if strings.Contains(include, k) {
-			include = strings.Replace(include, k, v, -1)
+// forcedIncludes 解析强制包含的头文件列表
+//
+// 相对路径在项目目录下存在时转换为绝对路径，否则保持原样，由编译器在include目录中查找。
+func (ev *Evaluation) forcedIncludes(files string) string {
+	var list []string
+	for _, f := range strings.Split(files, ";") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
 		}
-		if strings.Contains(def, k) {
-			def = strings.Replace(def, k, v, -1)
-		}
-		if strings.Contains(additionalOpts, k) {
-			additionalOpts = strings.Replace(additionalOpts, k, v, -1)
-		}
-		if strings.Contains(usingDirs, k) {
-			usingDirs = strings.Replace(usingDirs, k, v, -1)
+		path := absItemPath(ev.Project.ProjectDir, f)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			f = path
 		}
+		list = append(list, f)
 	}
-
-	return include, def, additionalOpts, usingDirs, nil
+	return strings.Join(list, ";")
 }
 
-// return include, definition,error
-func (pro *Project) FindConfig(conf string) (string, string, error) {
-	var cfgList []ProjectConfiguration
-	for _, v := range pro.ItemGroup {
-		if len(v.ProjectConfigurationList) > 0 {
-			cfgList = v.ProjectConfigurationList
-			break
+// defaultDefinitions 返回由项目属性决定的默认宏定义
+//
+// CharacterSet、UseOfMfc、UseOfAtl和DynamicLibrary对应的宏与Microsoft.Cpp.props注入的一致，
+// _LIB、_USRDLL、_CONSOLE等与Visual Studio项目模板添加的一致；
+// Debug配置按UseDebugLibraries确定，未设置时按配置名判断。结果只与项目属性有关，同一次求值只计算一次。
+func (ev *Evaluation) defaultDefinitions() []string {
+	if ev.defaultDefs != nil {
+		return ev.defaultDefs
+	}
+	props := ev.Properties
+	defs := []string{"WIN32"}
+
+	if ev.isDebug() {
+		defs = append(defs, "_DEBUG", "DEBUG")
+	} else {
+		defs = append(defs, "NDEBUG")
+	}
+	switch strings.ToLower(ev.Platform) {
+	case "win32":
+		defs = append(defs, "_WIN32")
+	case "x64":
+		defs = append(defs, "_WIN64")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(props.Get("ConfigurationType"))) {
+	case "application":
+		link, _ := ev.definition("Link")
+		if strings.EqualFold(strings.TrimSpace(link.Get("SubSystem")), "Windows") {
+			defs = append(defs, "_WINDOWS")
+		} else {
+			defs = append(defs, "_CONSOLE")
 		}
+	case "dynamiclibrary":
+		defs = append(defs, "_WINDOWS", "_USRDLL", "_WINDLL")
+	case "staticlibrary":
+		defs = append(defs, "_LIB")
 	}
 
-	// 收集所有可用配置
-	availableConfigs := []string{}
-	for _, v := range cfgList {
-		availableConfigs = append(availableConfigs, v.Include)
+	switch strings.ToLower(strings.TrimSpace(props.Get("CharacterSet"))) {
+	case "unicode":
+		defs = append(defs, "UNICODE", "_UNICODE")
+	case "multibyte":
+		defs = append(defs, "_MBCS")
 	}
-
-	if len(cfgList) == 0 {
-		return "", "", fmt.Errorf("%s: no configurations found", pro.ProjectPath)
+	if strings.EqualFold(strings.TrimSpace(props.Get("UseOfMfc")), "Dynamic") {
+		defs = append(defs, "_AFXDLL")
 	}
-
-	// 检查配置是否存在
-	found := false
-	var matchedConfig string
-
-	// 首先尝试完全匹配
-	for _, v := range cfgList {
-		if v.Include == conf {
-			found = true
-			matchedConfig = conf
-			break
-		}
+	switch strings.ToLower(strings.TrimSpace(props.Get("UseOfAtl"))) {
+	case "static":
+		defs = append(defs, "_ATL_STATIC_REGISTRY")
+	case "dynamic":
+		defs = append(defs, "_ATL_DLL")
 	}
+	ev.defaultDefs = defs
+	return defs
+}
 
-	// 如果完全匹配失败，尝试查找相同平台的其他配置
-	if !found {
-		// 解析用户请求的配置和平台
-		requestedParts := strings.Split(conf, "|")
-		if len(requestedParts) == 2 {
-			requestedPlatform := requestedParts[1]
-
-			// 查找相同平台的配置
-			for _, v := range cfgList {
-				configParts := strings.Split(v.Include, "|")
-				if len(configParts) == 2 && configParts[1] == requestedPlatform {
-					matchedConfig = v.Include
-					found = true
-					fmt.Fprintf(os.Stderr, "Warning: Configuration %s not found, using %s instead\n", conf, matchedConfig)
-					break
-				}
-			}
-		}
+// isDebug 判断当前配置是否为Debug配置：按UseDebugLibraries确定，未设置时按配置名判断
+func (ev *Evaluation) isDebug() bool {
+	if v := strings.TrimSpace(ev.Properties.Get("UseDebugLibraries")); v != "" {
+		return isTrue(v)
 	}
+	return strings.Contains(strings.ToLower(ev.Configuration), "debug")
+}
 
-	// 如果仍然没有找到匹配的配置，返回错误并列出可用配置
-	if !found {
-		return "", "", fmt.Errorf("%s:not found %s\nAvailable configurations: %v", pro.ProjectPath, conf, availableConfigs)
-	}
-	for _, v := range pro.ItemDefinitionGroup {
-		// 使用匹配的配置而不是原始请求的配置
-		if strings.Contains(v.Condition, matchedConfig) {
-			cl := v.ClCompile
-
-			vlist := strings.Split(matchedConfig, "|")
-			configuration := vlist[0]
-			platform := vlist[1]
-
-			willReplaceEnv := map[string]string{
-				"$(ProjectDir)":        pro.ProjectDir,
-				"$(Configuration)":     configuration,
-				"$(ConfigurationName)": configuration,
-				"$(Platform)":          platform,
-			}
-			for _, v := range os.Environ() {
-				kv := strings.Split(v, "=")
-				willReplaceEnv[fmt.Sprintf("$(%s)", kv[0])] = kv[1]
-			}
-
-			include := cl.AdditionalIncludeDirectories
-			def := cl.PreprocessorDefinitions
+// VCDirectories 项目属性中"VC++目录"页的设置
+type VCDirectories struct {
+	// 头文件目录，对应cl.exe的INCLUDE环境变量
+	IncludePath string
+	// 外部头文件目录，对应EXTERNAL_INCLUDE环境变量
+	ExternalIncludePath string
+	// #using引用的程序集目录，对应LIBPATH环境变量
+	ReferencePath string
+	// 可执行文件目录，对应PATH环境变量
+	ExecutablePath string
+}
 
-			// 处理预处理器定义的环境变量替换
-			for k, v := range willReplaceEnv {
-				if strings.Contains(include, k) {
-					include = strings.Replace(include, k, v, -1)
-				}
-				if strings.Contains(def, k) {
-					def = strings.Replace(def, k, v, -1)
-				}
-			}
+// VCDirectories 返回当前配置下的VC++目录
+//
+// 未导入Microsoft.Cpp.props时，其中引用的$(VC_IncludePath)等属性展开为空字符串，
+// 只保留项目自己添加的目录。
+func (ev *Evaluation) VCDirectories() VCDirectories {
+	return VCDirectories{
+		IncludePath:         ev.Properties.Get("IncludePath"),
+		ExternalIncludePath: ev.Properties.Get("ExternalIncludePath"),
+		ReferencePath:       ev.Properties.Get("ReferencePath"),
+		ExecutablePath:      ev.Properties.Get("ExecutablePath"),
+	}
+}
 
-			re := regexp.MustCompile(`\$\(.+\)`)
-			badEnv := re.FindAllString(include, -1)
-			if len(badEnv) > 0 {
-				//fmt.Fprintf(os.Stderr, "%s:bad env[%v]\n", pro.ProjectPath, badEnv[:])
-				//for _, v := range badEnv {
-				//	include = strings.Replace(include, v, "", -1)
-				//}
-			}
+// return include, definition,error
+func (pro *Project) FindConfig(conf string) (string, string, error) {
+	if len(pro.configurations()) == 0 {
+		return "", "", fmt.Errorf("%s: no configurations found", pro.ProjectPath)
+	}
+	ev, err := pro.Evaluate(conf)
+	if err != nil {
+		return "", "", err
+	}
 
-			return include, def, nil
-		}
+	cl, ok := ev.definition("ClCompile")
+	if !ok {
+		return "", "", errors.New("not found " + conf)
 	}
-	return "", "", errors.New("not found " + conf)
+	return cl.Get("AdditionalIncludeDirectories"), cl.Get("PreprocessorDefinitions"), nil
 }
 
-func (pro *Project) FindSourceFiles() []string {
-	var fileList []string
-	for _, v := range pro.ItemGroup {
-		for _, clCompile := range v.ClCompileList {
-			fileList = append(fileList, clCompile.Include)
+// configurations 返回项目中声明的ProjectConfiguration，格式为Configuration|Platform
+func (pro *Project) configurations() []string {
+	var list []string
+	for _, group := range pro.ItemGroup {
+		for _, v := range group.Items {
+			if v.Type() == "ProjectConfiguration" {
+				list = append(list, v.Include)
+			}
 		}
 	}
-	return fileList
+	return list
 }
 
-// 收集ItemGroup中的额外配置
-func (pro *Project) FindItemGroupConfigs(conf string) (string, string, string) {
-	var extraIncludes, extraDefs, extraOpts []string
+// matchConfig 在项目的配置列表中查找conf，找不到时尝试使用相同平台的其他配置
+func (pro *Project) matchConfig(conf string) (string, error) {
+	cfgList := pro.configurations()
 
-	// 遍历所有ItemGroup
-	for _, itemGroup := range pro.ItemGroup {
-		// 遍历当前ItemGroup中的所有ClCompile项
-		for _, clCompile := range itemGroup.ClCompileList {
-			// 收集所有ClCompile项的配置
-			if strings.TrimSpace(clCompile.AdditionalIncludeDirectories) != "" {
-				extraIncludes = append(extraIncludes, clCompile.AdditionalIncludeDirectories)
-			}
-			if strings.TrimSpace(clCompile.PreprocessorDefinitions) != "" {
-				extraDefs = append(extraDefs, clCompile.PreprocessorDefinitions)
-			}
-			if strings.TrimSpace(clCompile.AdditionalOptions) != "" {
-				extraOpts = append(extraOpts, clCompile.AdditionalOptions)
-			}
+	// 查找完全匹配的配置
+	for _, v := range cfgList {
+		if v == conf {
+			return v, nil
 		}
 	}
 
-	return strings.Join(extraIncludes, ";"),
-		strings.Join(extraDefs, ";"),
-		strings.Join(extraOpts, ";")
-}
-
-// RemoveBadOptions 移除%(AdditionalOptions)引用
-func RemoveBadOptions(opts string) string {
-	for _, bad := range badOpts {
-		opts = strings.Replace(opts, bad, "", -1)
+	// 如果完全匹配失败，尝试查找相同平台的其他配置
+	requestedParts := strings.Split(conf, "|")
+	if len(requestedParts) == 2 {
+		requestedPlatform := requestedParts[1]
+
+		// 查找相同平台的配置
+		for _, v := range cfgList {
+			configParts := strings.Split(v, "|")
+			if len(configParts) == 2 && configParts[1] == requestedPlatform {
+				fmt.Fprintf(os.Stderr, "Warning: Configuration %s not found, using %s instead\n", conf, v)
+				return v, nil
+			}
+		}
 	}
-	return opts
-}
 
-func RemoveBadInclude(include string) string {
-	for _, bad := range badInclude {
-		include = strings.Replace(include, bad, "", -1)
-	}
-	// 移除可能产生的多余分号
-	for strings.Contains(include, ";;") {
-		include = strings.Replace(include, ";;", ";", -1)
-	}
-	// 移除开头和结尾的分号
-	include = strings.Trim(include, ";")
-	return include
+	// 如果仍然没有找到匹配的配置，返回错误并列出可用配置
+	return "", fmt.Errorf("%s:not found %s\nAvailable configurations: %v", pro.ProjectPath, conf, cfgList)
 }
 
-func RemoveBadDefinition(def string) string {
-	for _, bad := range badDef {
-		def = strings.Replace(def, bad, "", -1)
-	}
-	// 移除可能产生的多余分号
-	for strings.Contains(def, ";;") {
-		def = strings.Replace(def, ";;", ";", -1)
+// FindSourceFiles 返回conf配置下参与编译的源文件
+//
+// 与Evaluation.SourceFiles一致：通配符已展开，Exclude、Remove、条件以及导入文件中的项均已处理，
+// 不包括被ExcludedFromBuild排除的文件。
+func (pro *Project) FindSourceFiles(conf string) ([]string, error) {
+	ev, err := pro.Evaluate(conf)
+	if err != nil {
+		return nil, err
 	}
-	// 移除开头和结尾的分号
-	def = strings.Trim(def, ";")
-	return def
+	return ev.SourceFiles(), nil
 }
 
 // 处理Conan等包管理器的路径
@@ -459,15 +526,130 @@ func MergeIncludeDirectories(dirs ...string) string {
 
 // 支持PropertyGroup和Import元素
 type PropertyGroup struct {
-	XMLName                      xml.Name `xml:"PropertyGroup"`
-	Condition                    string   `xml:"Condition,attr"`
-	Label                        string   `xml:"Label,attr"`
-	AdditionalIncludeDirectories string   `xml:"AdditionalIncludeDirectories"`
-	IncludeDirectories           string   `xml:"IncludeDirectories"`
+	XMLName    xml.Name   `xml:"PropertyGroup"`
+	Condition  string     `xml:"Condition,attr"`
+	Label      string     `xml:"Label,attr"`
+	Properties []Property `xml:",any"`
+}
+
+// PropertyGroup中的单个属性，元素名即属性名
+type Property struct {
+	XMLName   xml.Name
+	Condition string `xml:"Condition,attr"`
+	Value     string `xml:",chardata"`
+}
+
+// Name 返回属性名
+func (p Property) Name() string {
+	return p.XMLName.Local
 }
 
 type Import struct {
 	XMLName   xml.Name `xml:"Import"`
 	Project   string   `xml:"Project,attr"`
 	Condition string   `xml:"Condition,attr"`
+	Label     string   `xml:"Label,attr"`
+}
+
+type ImportGroup struct {
+	XMLName   xml.Name `xml:"ImportGroup"`
+	Condition string   `xml:"Condition,attr"`
+	Label     string   `xml:"Label,attr"`
+	Import    []Import `xml:"Import"`
+}
+
+// Choose 按顺序选择第一个条件成立的When，都不成立时使用Otherwise
+type Choose struct {
+	When []When
+	// 没有Otherwise元素时为nil，Otherwise的Condition总是为空
+	Otherwise *When
+}
+
+// When Choose中的一个分支，可以包含PropertyGroup、ItemGroup和嵌套的Choose
+type When struct {
+	Condition     string
+	PropertyGroup []PropertyGroup
+	ItemGroup     []ItemGroup
+	Choose        []Choose
+
+	// 子元素的文档顺序，求值时按此顺序处理
+	elements []elementRef
+}
+
+// UnmarshalXML 解析Choose元素中的When和Otherwise
+func (c *Choose) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var v When
+			if err := d.DecodeElement(&v, &t); err != nil {
+				return err
+			}
+			switch t.Name.Local {
+			case "When":
+				c.When = append(c.When, v)
+			case "Otherwise":
+				v.Condition = ""
+				c.Otherwise = &v
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// UnmarshalXML 解析When或Otherwise元素，同时记录子元素的文档顺序
+func (w *When) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "Condition" {
+			w.Condition = attr.Value
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := w.decodeElement(d, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (w *When) decodeElement(d *xml.Decoder, start xml.StartElement) error {
+	var err error
+	var ref elementRef
+	switch start.Name.Local {
+	case "PropertyGroup":
+		var v PropertyGroup
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementPropertyGroup, len(w.PropertyGroup)}
+		w.PropertyGroup = append(w.PropertyGroup, v)
+	case "ItemGroup":
+		var v ItemGroup
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementItemGroup, len(w.ItemGroup)}
+		w.ItemGroup = append(w.ItemGroup, v)
+	case "Choose":
+		var v Choose
+		err = d.DecodeElement(&v, &start)
+		ref = elementRef{elementChoose, len(w.Choose)}
+		w.Choose = append(w.Choose, v)
+	default:
+		return d.Skip()
+	}
+	if err != nil {
+		return err
+	}
+	w.elements = append(w.elements, ref)
+	return nil
 }
@@ -0,0 +1,175 @@
+package sln
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 属性函数参数嵌套展开的最大深度
+const maxExpandDepth = 32
+
+// PropertySet 保存MSBuild属性，属性名不区分大小写
+//
+// 查找优先级从高到低依次为：全局属性、项目中定义的属性、环境变量。
+type PropertySet struct {
+	global map[string]string
+	values map[string]string
+	env    map[string]string
+	// 正在求值的文件，用于属性函数出错时的诊断信息
+	source string
+}
+
+// NewPropertySet 创建属性集，并以当前进程的环境变量作为最低优先级的来源
+func NewPropertySet() *PropertySet {
+	ps := &PropertySet{
+		global: map[string]string{},
+		values: map[string]string{},
+		env:    map[string]string{},
+	}
+	for _, v := range os.Environ() {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) == 2 && kv[0] != "" {
+			ps.env[strings.ToLower(kv[0])] = kv[1]
+		}
+	}
+	return ps
+}
+
+// SetGlobal 设置全局属性，全局属性不会被项目文件中的定义覆盖
+func (ps *PropertySet) SetGlobal(name, value string) {
+	ps.global[strings.ToLower(name)] = value
+}
+
+// Set 设置属性值，后定义的值覆盖先定义的值
+func (ps *PropertySet) Set(name, value string) {
+	key := strings.ToLower(name)
+	if _, ok := ps.global[key]; ok {
+		return
+	}
+	ps.values[key] = value
+}
+
+// Lookup 查找属性值，找不到时第二个返回值为false
+func (ps *PropertySet) Lookup(name string) (string, bool) {
+	key := strings.ToLower(name)
+	if v, ok := ps.global[key]; ok {
+		return v, true
+	}
+	if v, ok := ps.values[key]; ok {
+		return v, true
+	}
+	if v, ok := ps.env[key]; ok {
+		return v, true
+	}
+	return "", false
+}
+
+// Get 获取属性值，未定义的属性返回空字符串
+func (ps *PropertySet) Get(name string) string {
+	v, _ := ps.Lookup(name)
+	return v
+}
+
+// Expand 展开字符串中的$(Name)引用和属性函数
+//
+// 与MSBuild一致，未定义的属性展开为空字符串；%(...)和@(...)保持原样。
+// 无法求值的属性函数输出警告并展开为空字符串。
+func (ps *PropertySet) Expand(s string) string {
+	return ps.expand(s, 0)
+}
+
+func (ps *PropertySet) expand(s string, depth int) string {
+	if depth > maxExpandDepth || !strings.Contains(s, "$(") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		if !strings.HasPrefix(s[i:], "$(") {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := matchingParen(s, i+1)
+		if end < 0 {
+			// 括号不匹配，原样保留剩余内容
+			sb.WriteString(s[i:])
+			break
+		}
+		expr := strings.TrimSpace(s[i+2 : end])
+		switch {
+		case isPropertyName(expr):
+			sb.WriteString(ps.expandProperty(expr))
+		case strings.HasPrefix(strings.ToLower(expr), "registry:"):
+			// $(Registry:...)读取注册表，这里统一视为未找到
+		default:
+			v, err := ps.evalPropertyFunction(expr, depth)
+			if err != nil {
+				ps.warnf("invalid property function %s: %v", s[i:end+1], err)
+			}
+			sb.WriteString(v)
+		}
+		i = end + 1
+	}
+	return sb.String()
+}
+
+// expandProperty 返回属性name的值
+//
+// 属性值在定义时已经展开，这里原样返回，值中的$(...)文本（例如来自环境变量）不会再次求值。
+func (ps *PropertySet) expandProperty(name string) string {
+	return ps.Get(name)
+}
+
+// warnf 输出求值过程中的警告
+func (ps *PropertySet) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if ps.source != "" {
+		msg = ps.source + ": " + msg
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+}
+
+// matchingParen 返回与open位置的左括号匹配的右括号位置，未找到返回-1
+func matchingParen(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isPropertyName 判断是否为合法的属性名
+func isPropertyName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case i > 0 && (c == '-' || (c >= '0' && c <= '9')):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package sln
+
+import (
+	"os"
+	"testing"
+)
+
+func TestExpandStoredValues(t *testing.T) {
+	os.Setenv("VS_EXPORT_TEST_LITERAL", "$(Inner)")
+	defer os.Unsetenv("VS_EXPORT_TEST_LITERAL")
+
+	ps := NewPropertySet()
+	ps.Set("Inner", "inner")
+	ps.Set("Outer", ps.Expand("$(Inner);x"))
+
+	tests := []struct {
+		expr, want string
+	}{
+		{"$(Outer)", "inner;x"},
+		{"-$(Undefined)-", "--"},
+		// 属性值在定义时已经展开，值中的$(...)文本不会再次求值
+		{"$(VS_EXPORT_TEST_LITERAL)", "$(Inner)"},
+	}
+	for _, tt := range tests {
+		if got := ps.Expand(tt.expr); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,714 @@
+package sln
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// propertyFunction 属性函数的参数个数和实现，maxArgs小于0表示参数个数不限
+type propertyFunction struct {
+	minArgs int
+	maxArgs int
+	call    func(ps *PropertySet, args []string) (string, error)
+}
+
+// stringMethod 字符串实例方法的参数个数和实现
+type stringMethod struct {
+	minArgs int
+	maxArgs int
+	call    func(s string, args []string) (string, error)
+}
+
+// staticPropertyFunctions 支持的静态属性函数，类型名和成员名均为小写
+//
+// 不带括号的静态属性（例如[System.Environment]::NewLine）按无参数的函数处理。
+var staticPropertyFunctions = map[string]map[string]propertyFunction{
+	"msbuild": {
+		"getdirectorynameoffileabove": {2, 2, func(ps *PropertySet, args []string) (string, error) {
+			return findFileAbove(ps.fullPath(args[0]), strings.TrimSpace(args[1])), nil
+		}},
+		"getpathoffileabove": {1, 2, func(ps *PropertySet, args []string) (string, error) {
+			start := ps.Get("MSBuildThisFileDirectory")
+			if len(args) == 2 {
+				start = args[1]
+			}
+			name := strings.TrimSpace(args[0])
+			dir := findFileAbove(ps.fullPath(start), name)
+			if dir == "" {
+				return "", nil
+			}
+			return filepath.Join(dir, name), nil
+		}},
+		"ensuretrailingslash": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return withTrailingSeparator(nativePath(args[0])), nil
+		}},
+		"normalizepath": {1, -1, func(ps *PropertySet, args []string) (string, error) {
+			return ps.fullPath(combinePaths(args)), nil
+		}},
+		"normalizedirectory": {1, -1, func(ps *PropertySet, args []string) (string, error) {
+			return withTrailingSeparator(ps.fullPath(combinePaths(args))), nil
+		}},
+		"makerelative": {2, 2, func(ps *PropertySet, args []string) (string, error) {
+			base, path := ps.fullPath(args[0]), ps.fullPath(args[1])
+			rel, err := filepath.Rel(base, path)
+			if err != nil {
+				return path, nil
+			}
+			if hasTrailingSeparator(args[1]) {
+				rel = withTrailingSeparator(rel)
+			}
+			return rel, nil
+		}},
+		"valueordefault": {2, 2, func(ps *PropertySet, args []string) (string, error) {
+			if args[0] != "" {
+				return args[0], nil
+			}
+			return args[1], nil
+		}},
+		"add":      arithmetic(func(a, b float64) float64 { return a + b }),
+		"subtract": arithmetic(func(a, b float64) float64 { return a - b }),
+		"multiply": arithmetic(func(a, b float64) float64 { return a * b }),
+		"divide":   arithmetic(func(a, b float64) float64 { return a / b }),
+		"modulo": {2, 2, func(ps *PropertySet, args []string) (string, error) {
+			a, b, err := intArgs(args)
+			if err != nil {
+				return "", err
+			}
+			if b == 0 {
+				return "", fmt.Errorf("division by zero")
+			}
+			return strconv.FormatInt(a%b, 10), nil
+		}},
+		"bitwiseor":  bitwise(func(a, b int64) int64 { return a | b }),
+		"bitwiseand": bitwise(func(a, b int64) int64 { return a & b }),
+		"bitwisexor": bitwise(func(a, b int64) int64 { return a ^ b }),
+		"isosplatform": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			name := strings.ToLower(strings.TrimSpace(args[0]))
+			return formatBool(name == runtime.GOOS || (name == "osx" && runtime.GOOS == "darwin")), nil
+		}},
+		"isosunixlike": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return formatBool(runtime.GOOS != "windows"), nil
+		}},
+		"versionequals":              versionCompare(func(c int) bool { return c == 0 }),
+		"versionnotequals":           versionCompare(func(c int) bool { return c != 0 }),
+		"versiongreaterthan":         versionCompare(func(c int) bool { return c > 0 }),
+		"versiongreaterthanorequals": versionCompare(func(c int) bool { return c >= 0 }),
+		"versionlessthan":            versionCompare(func(c int) bool { return c < 0 }),
+		"versionlessthanorequals":    versionCompare(func(c int) bool { return c <= 0 }),
+		"escape": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return escapeMSBuild(args[0]), nil
+		}},
+		"unescape": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return unescapeMSBuild(args[0]), nil
+		}},
+		// 注册表只在Windows上存在，这里统一视为未找到
+		"getregistryvalue": {2, -1, func(ps *PropertySet, args []string) (string, error) {
+			return "", nil
+		}},
+		"getregistryvaluefromview": {3, -1, func(ps *PropertySet, args []string) (string, error) {
+			return "", nil
+		}},
+	},
+	"system.io.path": {
+		"combine": {1, -1, func(ps *PropertySet, args []string) (string, error) {
+			return combinePaths(args), nil
+		}},
+		"getfullpath": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return ps.fullPath(args[0]), nil
+		}},
+		"getdirectoryname": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			path := nativePath(args[0])
+			i := strings.LastIndexByte(path, filepath.Separator)
+			if i < 0 {
+				return "", nil
+			}
+			if i == 0 {
+				return path[:1], nil
+			}
+			return path[:i], nil
+		}},
+		"getfilename": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return pathFileName(args[0]), nil
+		}},
+		"getfilenamewithoutextension": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			name := pathFileName(args[0])
+			return strings.TrimSuffix(name, filepath.Ext(name)), nil
+		}},
+		"getextension": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return filepath.Ext(pathFileName(args[0])), nil
+		}},
+		"changeextension": {2, 2, func(ps *PropertySet, args []string) (string, error) {
+			path := nativePath(args[0])
+			path = strings.TrimSuffix(path, filepath.Ext(pathFileName(path)))
+			ext := args[1]
+			if ext != "" && !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			return path + ext, nil
+		}},
+		"hasextension": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return formatBool(filepath.Ext(pathFileName(args[0])) != ""), nil
+		}},
+		"ispathrooted": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return formatBool(isRootedPath(args[0])), nil
+		}},
+		"gettemppath": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return withTrailingSeparator(os.TempDir()), nil
+		}},
+		"directoryseparatorchar": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return string(filepath.Separator), nil
+		}},
+	},
+	"system.io.file": {
+		"exists": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			info, err := os.Stat(ps.fullPath(args[0]))
+			return formatBool(err == nil && !info.IsDir()), nil
+		}},
+	},
+	"system.io.directory": {
+		"exists": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			info, err := os.Stat(ps.fullPath(args[0]))
+			return formatBool(err == nil && info.IsDir()), nil
+		}},
+	},
+	"system.string": {
+		"isnullorempty": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return formatBool(args[0] == ""), nil
+		}},
+		"isnullorwhitespace": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return formatBool(strings.TrimSpace(args[0]) == ""), nil
+		}},
+		"copy": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return args[0], nil
+		}},
+		"concat": {1, -1, func(ps *PropertySet, args []string) (string, error) {
+			return strings.Join(args, ""), nil
+		}},
+		"join": {2, -1, func(ps *PropertySet, args []string) (string, error) {
+			return strings.Join(args[1:], args[0]), nil
+		}},
+		"equals": {2, 2, func(ps *PropertySet, args []string) (string, error) {
+			return formatBool(args[0] == args[1]), nil
+		}},
+		"format": {1, -1, func(ps *PropertySet, args []string) (string, error) {
+			s := args[0]
+			for i, v := range args[1:] {
+				s = strings.Replace(s, "{"+strconv.Itoa(i)+"}", v, -1)
+			}
+			return s, nil
+		}},
+		"empty": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return "", nil
+		}},
+	},
+	"system.environment": {
+		"getenvironmentvariable": {1, 1, func(ps *PropertySet, args []string) (string, error) {
+			return os.Getenv(args[0]), nil
+		}},
+		"newline": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			if runtime.GOOS == "windows" {
+				return "\r\n", nil
+			}
+			return "\n", nil
+		}},
+		"machinename": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return os.Hostname()
+		}},
+		"processorcount": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return strconv.Itoa(runtime.NumCPU()), nil
+		}},
+		"is64bitoperatingsystem": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return formatBool(strconv.IntSize == 64), nil
+		}},
+		"currentdirectory": {0, 0, func(ps *PropertySet, args []string) (string, error) {
+			return os.Getwd()
+		}},
+	},
+}
+
+// stringMethods 支持的字符串实例方法和属性，名称均为小写，下标按字符计算
+var stringMethods = map[string]stringMethod{
+	"length": {0, 0, func(s string, args []string) (string, error) {
+		return strconv.Itoa(utf8.RuneCountInString(s)), nil
+	}},
+	"replace": {2, 2, func(s string, args []string) (string, error) {
+		if args[0] == "" {
+			return "", fmt.Errorf("Replace() old value is empty")
+		}
+		return strings.Replace(s, args[0], args[1], -1), nil
+	}},
+	"tolower":          {0, 0, func(s string, args []string) (string, error) { return strings.ToLower(s), nil }},
+	"tolowerinvariant": {0, 0, func(s string, args []string) (string, error) { return strings.ToLower(s), nil }},
+	"toupper":          {0, 0, func(s string, args []string) (string, error) { return strings.ToUpper(s), nil }},
+	"toupperinvariant": {0, 0, func(s string, args []string) (string, error) { return strings.ToUpper(s), nil }},
+	"trim": {0, -1, func(s string, args []string) (string, error) {
+		if len(args) == 0 {
+			return strings.TrimSpace(s), nil
+		}
+		return strings.Trim(s, strings.Join(args, "")), nil
+	}},
+	"trimstart": {0, -1, func(s string, args []string) (string, error) {
+		if len(args) == 0 {
+			return strings.TrimLeft(s, " \t\r\n"), nil
+		}
+		return strings.TrimLeft(s, strings.Join(args, "")), nil
+	}},
+	"trimend": {0, -1, func(s string, args []string) (string, error) {
+		if len(args) == 0 {
+			return strings.TrimRight(s, " \t\r\n"), nil
+		}
+		return strings.TrimRight(s, strings.Join(args, "")), nil
+	}},
+	"substring": {1, 2, func(s string, args []string) (string, error) {
+		r := []rune(s)
+		start, err := strconv.Atoi(strings.TrimSpace(args[0]))
+		if err != nil || start < 0 || start > len(r) {
+			return "", fmt.Errorf("Substring() start index %q is out of range", args[0])
+		}
+		end := len(r)
+		if len(args) == 2 {
+			n, err := strconv.Atoi(strings.TrimSpace(args[1]))
+			if err != nil || n < 0 || start+n > len(r) {
+				return "", fmt.Errorf("Substring() length %q is out of range", args[1])
+			}
+			end = start + n
+		}
+		return string(r[start:end]), nil
+	}},
+	"startswith": {1, 1, func(s string, args []string) (string, error) {
+		return formatBool(strings.HasPrefix(s, args[0])), nil
+	}},
+	"endswith": {1, 1, func(s string, args []string) (string, error) {
+		return formatBool(strings.HasSuffix(s, args[0])), nil
+	}},
+	"contains": {1, 1, func(s string, args []string) (string, error) {
+		return formatBool(strings.Contains(s, args[0])), nil
+	}},
+	"equals": {1, 1, func(s string, args []string) (string, error) {
+		return formatBool(s == args[0]), nil
+	}},
+	"indexof": {1, 1, func(s string, args []string) (string, error) {
+		return strconv.Itoa(runeIndex(s, strings.Index(s, args[0]))), nil
+	}},
+	"lastindexof": {1, 1, func(s string, args []string) (string, error) {
+		return strconv.Itoa(runeIndex(s, strings.LastIndex(s, args[0]))), nil
+	}},
+	"padleft": {1, 2, func(s string, args []string) (string, error) {
+		pad, err := padding(s, args)
+		return pad + s, err
+	}},
+	"padright": {1, 2, func(s string, args []string) (string, error) {
+		pad, err := padding(s, args)
+		return s + pad, err
+	}},
+	"insert": {2, 2, func(s string, args []string) (string, error) {
+		r := []rune(s)
+		i, err := strconv.Atoi(strings.TrimSpace(args[0]))
+		if err != nil || i < 0 || i > len(r) {
+			return "", fmt.Errorf("Insert() index %q is out of range", args[0])
+		}
+		return string(r[:i]) + args[1] + string(r[i:]), nil
+	}},
+	"remove": {1, 2, func(s string, args []string) (string, error) {
+		r := []rune(s)
+		start, err := strconv.Atoi(strings.TrimSpace(args[0]))
+		if err != nil || start < 0 || start > len(r) {
+			return "", fmt.Errorf("Remove() start index %q is out of range", args[0])
+		}
+		end := len(r)
+		if len(args) == 2 {
+			n, err := strconv.Atoi(strings.TrimSpace(args[1]))
+			if err != nil || n < 0 || start+n > len(r) {
+				return "", fmt.Errorf("Remove() count %q is out of range", args[1])
+			}
+			end = start + n
+		}
+		return string(r[:start]) + string(r[end:]), nil
+	}},
+}
+
+// evalPropertyFunction 求值$(...)中不是简单属性名的表达式，expr不包括外层的$(和)
+//
+// 支持属性上的字符串实例方法（$(Foo.Replace('a','b'))）、静态函数
+// （$([System.IO.Path]::Combine(a, b))）以及两者的链式调用。参数可以带引号，
+// 其中的$(...)引用会先展开。
+func (ps *PropertySet) evalPropertyFunction(expr string, depth int) (string, error) {
+	p := &propertyExprParser{ps: ps, s: expr, depth: depth}
+	p.skipSpace()
+
+	var value string
+	if p.peek() == '[' {
+		v, err := p.parseStaticCall()
+		if err != nil {
+			return "", err
+		}
+		value = v
+	} else {
+		name := p.parseName(isPropertyNameChar)
+		if name == "" {
+			return "", fmt.Errorf("expected property name or [Type]::Member at %d", p.pos)
+		}
+		value = ps.expandProperty(name)
+	}
+
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return value, nil
+		}
+		if p.peek() != '.' {
+			return "", fmt.Errorf("unexpected %q at %d", p.peek(), p.pos)
+		}
+		p.pos++
+		name := p.parseName(isMemberNameChar)
+		method, ok := stringMethods[strings.ToLower(name)]
+		if !ok {
+			return "", fmt.Errorf("unknown string method %s", name)
+		}
+		args, err := p.parseArgs()
+		if err != nil {
+			return "", err
+		}
+		if err := checkArgCount(name, len(args), method.minArgs, method.maxArgs); err != nil {
+			return "", err
+		}
+		if value, err = method.call(value, args); err != nil {
+			return "", err
+		}
+	}
+}
+
+// propertyExprParser 解析属性函数表达式
+type propertyExprParser struct {
+	ps    *PropertySet
+	s     string
+	pos   int
+	depth int
+}
+
+func (p *propertyExprParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *propertyExprParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *propertyExprParser) skipSpace() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\r' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *propertyExprParser) parseName(valid func(c byte) bool) string {
+	p.skipSpace()
+	start := p.pos
+	for !p.eof() && valid(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// parseStaticCall 解析[Type]::Member或[Type]::Member(args)
+func (p *propertyExprParser) parseStaticCall() (string, error) {
+	end := strings.IndexByte(p.s[p.pos:], ']')
+	if end < 0 {
+		return "", fmt.Errorf("missing ']' after type name")
+	}
+	typeName := strings.TrimSpace(p.s[p.pos+1 : p.pos+end])
+	p.pos += end + 1
+	p.skipSpace()
+	if !strings.HasPrefix(p.s[p.pos:], "::") {
+		return "", fmt.Errorf("expected '::' after [%s]", typeName)
+	}
+	p.pos += 2
+	member := p.parseName(isMemberNameChar)
+	if member == "" {
+		return "", fmt.Errorf("expected member name after [%s]::", typeName)
+	}
+
+	functions, ok := staticPropertyFunctions[strings.ToLower(typeName)]
+	if !ok {
+		return "", fmt.Errorf("unknown type [%s]", typeName)
+	}
+	fn, ok := functions[strings.ToLower(member)]
+	if !ok {
+		return "", fmt.Errorf("unknown function [%s]::%s", typeName, member)
+	}
+	args, err := p.parseArgs()
+	if err != nil {
+		return "", err
+	}
+	if err := checkArgCount("["+typeName+"]::"+member, len(args), fn.minArgs, fn.maxArgs); err != nil {
+		return "", err
+	}
+	return fn.call(p.ps, args)
+}
+
+// parseArgs 解析可选的参数列表，没有括号时返回nil
+func (p *propertyExprParser) parseArgs() ([]string, error) {
+	p.skipSpace()
+	if p.peek() != '(' {
+		return nil, nil
+	}
+	end := matchingParen(p.s, p.pos)
+	if end < 0 {
+		return nil, fmt.Errorf("unbalanced parenthesis at %d", p.pos)
+	}
+	inner := p.s[p.pos+1 : end]
+	p.pos = end + 1
+	if strings.TrimSpace(inner) == "" {
+		return nil, nil
+	}
+
+	var args []string
+	for _, raw := range splitArgs(inner) {
+		arg := strings.TrimSpace(raw)
+		if len(arg) >= 2 && strings.IndexByte("'\"`", arg[0]) >= 0 && arg[len(arg)-1] == arg[0] {
+			arg = arg[1 : len(arg)-1]
+		}
+		args = append(args, p.ps.expand(arg, p.depth+1))
+	}
+	return args, nil
+}
+
+// splitArgs 按不在引号和括号内的逗号拆分参数
+func splitArgs(s string) []string {
+	var args []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, s[start:])
+}
+
+func checkArgCount(name string, n, min, max int) error {
+	if n < min || (max >= 0 && n > max) {
+		if min == max {
+			return fmt.Errorf("%s takes %d argument(s), got %d", name, min, n)
+		}
+		return fmt.Errorf("%s takes at least %d argument(s), got %d", name, min, n)
+	}
+	return nil
+}
+
+func isPropertyNameChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isMemberNameChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// formatBool 与.NET一致，布尔值转换为True或False
+func formatBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
+
+// fullPath 返回绝对路径，相对路径基于项目目录，保留末尾的路径分隔符
+func (ps *PropertySet) fullPath(path string) string {
+	path = nativePath(strings.TrimSpace(path))
+	trailing := hasTrailingSeparator(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ps.Get("MSBuildProjectDirectory"), path)
+	}
+	path = filepath.Clean(path)
+	if trailing {
+		path = withTrailingSeparator(path)
+	}
+	return path
+}
+
+// combinePaths 与Path.Combine一致：依次拼接路径，遇到绝对路径时从该路径重新开始
+func combinePaths(parts []string) string {
+	result := ""
+	for _, part := range parts {
+		part = nativePath(part)
+		switch {
+		case part == "":
+		case isRootedPath(part) || result == "":
+			result = part
+		default:
+			result = withTrailingSeparator(result) + part
+		}
+	}
+	return result
+}
+
+func isRootedPath(path string) bool {
+	path = nativePath(path)
+	if filepath.IsAbs(path) || strings.HasPrefix(path, string(filepath.Separator)) {
+		return true
+	}
+	// Windows路径的盘符
+	return len(path) >= 2 && path[1] == ':'
+}
+
+func hasTrailingSeparator(path string) bool {
+	return strings.HasSuffix(path, "/") || strings.HasSuffix(path, "\\")
+}
+
+// pathFileName 返回路径中最后一个分隔符之后的部分
+func pathFileName(path string) string {
+	path = nativePath(path)
+	return path[strings.LastIndexByte(path, filepath.Separator)+1:]
+}
+
+// runeIndex 把字节下标转换为字符下标，-1保持不变
+func runeIndex(s string, i int) int {
+	if i < 0 {
+		return i
+	}
+	return utf8.RuneCountInString(s[:i])
+}
+
+// padding 返回PadLeft/PadRight需要补充的字符
+func padding(s string, args []string) (string, error) {
+	width, err := strconv.Atoi(strings.TrimSpace(args[0]))
+	if err != nil {
+		return "", fmt.Errorf("invalid width %q", args[0])
+	}
+	pad := " "
+	if len(args) == 2 && args[1] != "" {
+		pad = args[1][:1]
+	}
+	n := width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return "", nil
+	}
+	return strings.Repeat(pad, n), nil
+}
+
+// arithmetic 两个参数的算术函数，两个参数都是整数时结果也是整数
+func arithmetic(op func(a, b float64) float64) propertyFunction {
+	return propertyFunction{2, 2, func(ps *PropertySet, args []string) (string, error) {
+		var nums [2]float64
+		for i, arg := range args {
+			n, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+			if err != nil {
+				return "", fmt.Errorf("%q is not a number", arg)
+			}
+			nums[i] = n
+		}
+		result := op(nums[0], nums[1])
+		if _, _, err := intArgs(args); err == nil && result == float64(int64(result)) {
+			return strconv.FormatInt(int64(result), 10), nil
+		}
+		return strconv.FormatFloat(result, 'f', -1, 64), nil
+	}}
+}
+
+func bitwise(op func(a, b int64) int64) propertyFunction {
+	return propertyFunction{2, 2, func(ps *PropertySet, args []string) (string, error) {
+		a, b, err := intArgs(args)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(op(a, b), 10), nil
+	}}
+}
+
+func intArgs(args []string) (int64, int64, error) {
+	var nums [2]int64
+	for i, arg := range args[:2] {
+		n, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%q is not an integer", arg)
+		}
+		nums[i] = n
+	}
+	return nums[0], nums[1], nil
+}
+
+// versionCompare 按点分隔的数字逐段比较两个版本号
+func versionCompare(match func(c int) bool) propertyFunction {
+	return propertyFunction{2, 2, func(ps *PropertySet, args []string) (string, error) {
+		a := strings.Split(strings.TrimPrefix(strings.TrimSpace(args[0]), "v"), ".")
+		b := strings.Split(strings.TrimPrefix(strings.TrimSpace(args[1]), "v"), ".")
+		c := 0
+		for i := 0; c == 0 && (i < len(a) || i < len(b)); i++ {
+			var x, y int
+			var err error
+			if i < len(a) {
+				if x, err = strconv.Atoi(a[i]); err != nil {
+					return "", fmt.Errorf("%q is not a version", args[0])
+				}
+			}
+			if i < len(b) {
+				if y, err = strconv.Atoi(b[i]); err != nil {
+					return "", fmt.Errorf("%q is not a version", args[1])
+				}
+			}
+			switch {
+			case x < y:
+				c = -1
+			case x > y:
+				c = 1
+			}
+		}
+		return formatBool(match(c)), nil
+	}}
+}
+
+// MSBuild中需要转义的特殊字符
+const msbuildSpecialChars = "%*?@$();'"
+
+func escapeMSBuild(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(msbuildSpecialChars, s[i]) >= 0 {
+			fmt.Fprintf(&sb, "%%%02x", s[i])
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// unescapeMSBuild 还原%XX形式的转义字符
+func unescapeMSBuild(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+package sln
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPropertyFunctions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "propfunc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// marker.props在根目录，项目在其下两级目录中
+	projectDir := filepath.Join(dir, "src", "app")
+	writeFiles(t, dir, "marker.props", "src/app/app.vcxproj")
+
+	ps := NewPropertySet()
+	ps.SetGlobal("MSBuildProjectDirectory", projectDir)
+	ps.SetGlobal("MSBuildThisFileDirectory", withTrailingSeparator(projectDir))
+	ps.Set("Foo", `a\b\c`)
+	ps.Set("Root", dir)
+	ps.Set("File", `inc\header.h`)
+	ps.Set("Padded", "  text  ")
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// 字符串实例方法
+		{`$(Foo.Replace('\','/'))`, "a/b/c"},
+		{`$(Foo.Length)`, "5"},
+		{`$(Foo.StartsWith('a'))`, "True"},
+		{`$(Foo.Substring(2))`, `b\c`},
+		{`$(Foo.IndexOf('b'))`, "2"},
+		{`$(Undefined.Length)`, "0"},
+
+		// 静态函数
+		{`$([System.IO.Path]::Combine('$(Root)', 'inc'))`, filepath.Join(dir, "inc")},
+		{`$([System.IO.Path]::Combine('$(Root)', 'a', 'b.h'))`, filepath.Join(dir, "a", "b.h")},
+		{`$([System.IO.Path]::Combine('ignored', '$(Root)'))`, dir},
+		{`$([System.IO.Path]::GetFullPath('..\inc'))`, filepath.Join(dir, "src", "inc")},
+		{`$([System.IO.Path]::GetFullPath('$(Root)\x\..\y'))`, filepath.Join(dir, "y")},
+		{`$([System.IO.Path]::GetFileName('$(File)'))`, "header.h"},
+		{`$([System.IO.Path]::GetExtension('$(File)'))`, ".h"},
+		{`$([MSBuild]::GetDirectoryNameOfFileAbove($(MSBuildThisFileDirectory), marker.props))`, dir},
+		{`$([MSBuild]::GetDirectoryNameOfFileAbove('$(MSBuildThisFileDirectory)', 'app.vcxproj'))`, projectDir},
+		{`$([MSBuild]::GetDirectoryNameOfFileAbove($(MSBuildThisFileDirectory), missing.props))`, ""},
+		{`$([MSBuild]::Add(1, 2))`, "3"},
+		{`$([MSBuild]::VersionGreaterThanOrEquals('10.0.19041', '10.0.17763'))`, "True"},
+		{`$([System.String]::IsNullOrEmpty('$(Undefined)'))`, "True"},
+
+		// 链式调用
+		{`$(Foo.Replace('\','/').ToUpper())`, "A/B/C"},
+		{`$(Padded.Trim().Length)`, "4"},
+		{`$([System.IO.Path]::GetFileName('$(File)').Replace('.h', '.hpp'))`, "header.hpp"},
+		{`$([System.String]::Concat('ab', 'cd').ToUpper().Substring(1, 2))`, "BC"},
+
+		// 属性函数可以与普通文本和属性引用混合
+		{`-I$(Foo.Replace('\','/'))/inc;$(Foo)`, `-Ia/b/c/inc;a\b\c`},
+	}
+	for _, tt := range tests {
+		if got := ps.Expand(tt.expr); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestPropertyFunctionErrors(t *testing.T) {
+	ps := NewPropertySet()
+	ps.Set("Foo", "abc")
+
+	tests := []struct {
+		expr string
+		// 错误信息中应包含的内容
+		want string
+	}{
+		{`[System.IO.Path]::Bogus('x')`, "unknown function [System.IO.Path]::Bogus"},
+		{`[System.Bogus]::Combine('x')`, "unknown type [System.Bogus]"},
+		{`Foo.Bogus()`, "unknown string method Bogus"},
+		{`Foo.Replace('a','b').Bogus`, "unknown string method Bogus"},
+		{`[System.IO.Path]::GetFileName()`, "takes 1 argument(s), got 0"},
+		{`Foo.Substring(10)`, "out of range"},
+		{`[MSBuild]::Add('x', 1)`, "is not a number"},
+		{`[System.IO.Path]Combine('x')`, "expected '::'"},
+		{`[System.IO.Path::Combine('x')`, "missing ']'"},
+		{`Foo.Replace('a','b'`, "unbalanced parenthesis"},
+	}
+	for _, tt := range tests {
+		_, err := ps.evalPropertyFunction(tt.expr, 0)
+		if err == nil {
+			t.Errorf("evalPropertyFunction(%q) succeeded, want error containing %q", tt.expr, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("evalPropertyFunction(%q) error = %q, want it to contain %q", tt.expr, err, tt.want)
+		}
+	}
+
+	// 无法求值的属性函数展开为空字符串，其余部分照常展开
+	if got := ps.Expand(`x$([System.IO.Path]::Bogus('x'))$(Foo)`); got != "xabc" {
+		t.Errorf("Expand() with unknown function = %q, want %q", got, "xabc")
+	}
+}
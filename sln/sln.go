@@ -3,16 +3,17 @@ package sln
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
 type Sln struct {
 	SolutionDir string
 	ProjectList []Project
+	Options     Options
+	// 从.sln、.slnx或.slnf加载时的解决方案结构，直接加载项目文件时为nil
+	Solution *SolutionFile
 }
 
 func NewSln(path string) (Sln, error) {
@@ -29,227 +30,217 @@ func NewSln(path string) (Sln, error) {
 	}
 	sln.SolutionDir = filepath.Dir(absPath)
 
-	if ext == ".sln" {
+	if ext == ".sln" || ext == ".slnx" {
 		// 处理解决方案文件
-		projectFiles, err := findAllProject(path)
+		sf, err := parseSolution(absPath)
 		if err != nil {
-			fmt.Println(err)
 			return sln, err
 		}
-		if len(projectFiles) == 0 {
-			return sln, errors.New("not found project file")
+		if err := sln.loadSolution(sf); err != nil {
+			return sln, err
 		}
-
-		for _, projectPath := range projectFiles {
-			pro, err := NewProject(filepath.Join(sln.SolutionDir, projectPath))
-			if err != nil {
-				return sln, err
-			}
-			sln.ProjectList = append(sln.ProjectList, pro)
+	} else if ext == ".slnf" {
+		// 处理解决方案筛选器，只加载其中列出的项目
+		filter, err := ParseSolutionFilter(absPath)
+		if err != nil {
+			return sln, err
 		}
-	} else if ext == ".vcxproj" {
+		// 项目路径和$(SolutionDir)都基于所引用的解决方案
+		sln.SolutionDir = filepath.Dir(filter.SolutionPath)
+		sf, err := parseSolution(filter.SolutionPath)
+		if err != nil {
+			return sln, err
+		}
+		if err := sln.loadSolution(filter.Apply(sf)); err != nil {
+			return sln, err
+		}
+	} else if ext == ".vcxproj" || ext == ".vcproj" {
 		// 直接处理单个项目文件
 		pro, err := NewProject(absPath)
 		if err != nil {
 			return sln, err
 		}
+		pro.SolutionDir = sln.SolutionDir
 		sln.ProjectList = append(sln.ProjectList, pro)
 	} else {
-		return sln, fmt.Errorf("unsupported file format: %s, only .sln and .vcxproj are supported", ext)
+		return sln, fmt.Errorf("unsupported file format: %s, only .sln, .slnx, .slnf, .vcxproj and .vcproj are supported", ext)
 	}
 
 	return sln, nil
 }
 
-func findAllProject(path string) ([]string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return []string{}, err
+// parseSolution 按扩展名解析.sln或.slnx文件
+func parseSolution(path string) (*SolutionFile, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sln":
+		return ParseSolutionFile(path)
+	case ".slnx":
+		return ParseSlnx(path)
 	}
-	defer f.Close()
-	b, err := ioutil.ReadAll(f)
-	if err != nil {
-		return []string{}, err
+	return nil, fmt.Errorf("unsupported solution format: %s", path)
+}
+
+// loadSolution 加载解决方案中的所有VC++项目
+func (sln *Sln) loadSolution(sf *SolutionFile) error {
+	sln.Solution = sf
+	for i := range sf.Projects {
+		entry := &sf.Projects[i]
+		if !entry.IsVcxproj() && !entry.IsVcproj() {
+			continue
+		}
+		pro, err := NewProject(filepath.Join(sln.SolutionDir, nativePath(entry.Path)))
+		if err != nil {
+			return err
+		}
+		pro.SolutionDir = sln.SolutionDir
+		pro.SolutionProject = entry
+		sln.ProjectList = append(sln.ProjectList, pro)
+	}
+	if len(sln.ProjectList) == 0 {
+		return errors.New("not found project file")
 	}
-	re := regexp.MustCompile("[^\"]\"[^\"]+\\.vcxproj\"")
-	files := re.FindAllString(string(b), -1)
-
-	var list []string
-	for _, v := range files {
-		v = strings.Replace(v, "\"", "", -1)
-		v = strings.TrimSpace(v)
-		list = append(list, v)
+	return nil
+}
+
+// projectConfiguration 返回解决方案配置conf映射到的项目配置以及项目是否参与生成，
+// 不在解决方案中或没有映射的项目直接使用conf
+func (pro *Project) projectConfiguration(conf string) (string, bool) {
+	if entry := pro.SolutionProject; entry != nil {
+		if mapped, build, ok := entry.ProjectConfiguration(conf); ok {
+			return mapped, build
+		}
 	}
-	return list, nil
+	return conf, true
 }
 
 // 生成compile_commands.json内容
 func (sln *Sln) CompileCommandsJson(conf string) ([]CompileCommand, error) {
 	var cmdList []CompileCommand
+	// 每个条目所属的项目在ProjectList中的下标，用于处理多个项目共享的文件
+	var owners []int
 
-	for _, pro := range sln.ProjectList {
-		var item CompileCommand
+	if sln.Solution != nil && !sln.Solution.HasConfiguration(conf) {
+		fmt.Fprintf(os.Stderr, "Warning: solution configuration %s not found, available configurations: %v\n",
+			conf, sln.Solution.Configurations)
+	}
 
-		for _, f := range pro.FindSourceFiles() {
-			item.Dir = pro.ProjectDir
-			item.File = f
-
-			// 使用增强的配置查找函数
-			inc, def, additionalOpts, usingDirs, err := pro.FindConfigEnhanced(conf)
-			if err != nil {
-				// 如果增强函数失败，回退到原始函数
-				inc, def, err = pro.FindConfig(conf)
-				if err != nil {
-					return cmdList, err
-				}
-			}
+	driver, err := NewDriver(sln.Options.Driver, sln.Options.DriverPath)
+	if err != nil {
+		return nil, err
+	}
 
-			// 收集ItemGroup中的额外配置
-			extraInc, extraDef, extraOpt := pro.FindItemGroupConfigs(conf)
+	for i, pro := range sln.ProjectList {
+		var item CompileCommand
 
-			// 处理SolutionDir环境变量替换
-			willReplaceEnv := map[string]string{
-				"$(SolutionDir)": sln.SolutionDir,
-			}
-			for k, v := range willReplaceEnv {
-				if strings.Contains(inc, k) {
-					inc = strings.Replace(inc, k, v, -1)
-				}
-				if strings.Contains(def, k) {
-					def = strings.Replace(def, k, v, -1)
-				}
-				if strings.Contains(additionalOpts, k) {
-					additionalOpts = strings.Replace(additionalOpts, k, v, -1)
-				}
-				if strings.Contains(usingDirs, k) {
-					usingDirs = strings.Replace(usingDirs, k, v, -1)
-				}
-				// 处理额外配置的环境变量替换
-				if strings.Contains(extraInc, k) {
-					extraInc = strings.Replace(extraInc, k, v, -1)
-				}
-				if strings.Contains(extraDef, k) {
-					extraDef = strings.Replace(extraDef, k, v, -1)
-				}
-				if strings.Contains(extraOpt, k) {
-					extraOpt = strings.Replace(extraOpt, k, v, -1)
-				}
-			}
+		// 按解决方案中的映射确定项目配置，跳过不参与生成的项目
+		proConf, build := pro.projectConfiguration(conf)
+		if !build {
+			fmt.Fprintf(os.Stderr, "Warning: %s is not built in solution configuration %s, skipped\n",
+				pro.ProjectPath, conf)
+			continue
+		}
 
-			// 合并所有include目录
-			allIncludeDirs := MergeSemicolonSeparatedLists(inc, usingDirs, extraInc)
-
-			// 添加系统include目录（基于MSVC标准路径）
-			var systemIncludeDirs []string
-			// 检查是否有Visual Studio环境变量
-			if vsInstallDir := os.Getenv("VSINSTALLDIR"); vsInstallDir != "" {
-				platformToolset := "v143" // 默认使用VS 2022的工具集
-				// 尝试从环境变量获取工具集版本
-				if toolset := os.Getenv("PlatformToolsetVersion"); toolset != "" {
-					platformToolset = toolset
-				}
-
-				// 添加MSVC标准库include目录
-				systemIncludeDirs = append(systemIncludeDirs, filepath.Join(vsInstallDir, "VC", "Tools", "MSVC", platformToolset, "include"))
-
-				// 添加Windows SDK include目录
-				if windowsSdkDir := os.Getenv("WindowsSdkDir"); windowsSdkDir != "" {
-					if windowsSdkVersion := os.Getenv("WindowsSdkVersion"); windowsSdkVersion != "" {
-						systemIncludeDirs = append(systemIncludeDirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "um"))
-						systemIncludeDirs = append(systemIncludeDirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "shared"))
-						systemIncludeDirs = append(systemIncludeDirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "winrt"))
-						systemIncludeDirs = append(systemIncludeDirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "cppwinrt"))
-					}
-				}
-			}
+		ev, err := pro.Evaluate(proConf)
+		if err != nil {
+			return cmdList, err
+		}
 
-			// 如果没有环境变量，添加默认的系统include目录位置
-			if len(systemIncludeDirs) == 0 {
-				// VS 2022默认安装路径
-				systemIncludeDirs = append(systemIncludeDirs, "C:\\Program Files\\Microsoft Visual Studio\\2022\\Community\\VC\\Tools\\MSVC\\14.39.33519\\include")
-				systemIncludeDirs = append(systemIncludeDirs, "C:\\Program Files (x86)\\Windows Kits\\10\\Include\\10.0.22621.0\\um")
-				systemIncludeDirs = append(systemIncludeDirs, "C:\\Program Files (x86)\\Windows Kits\\10\\Include\\10.0.22621.0\\shared")
-				systemIncludeDirs = append(systemIncludeDirs, "C:\\Program Files (x86)\\Windows Kits\\10\\Include\\10.0.22621.0\\winrt")
-			}
+		for _, src := range ev.Items("ClCompile") {
+			item.Dir = pro.ProjectDir
+			// file字段由当前系统上的工具读取，使用当前系统的路径分隔符
+			item.File = nativePath(src.Include)
 
-			// 合并系统include目录
-			if len(systemIncludeDirs) > 0 {
-				allIncludeDirs = MergeSemicolonSeparatedLists(allIncludeDirs, strings.Join(systemIncludeDirs, ";"))
+			// 处理当前配置下被排除的文件
+			item.Excluded = ev.excludedFromBuild(src)
+			if item.Excluded && sln.Options.Excluded != ExcludedMark {
+				continue
 			}
 
-			// 合并所有宏定义
-			allDefs := MergeSemicolonSeparatedLists(def, extraDef)
+			item.Cmd = driver.CommandLine(driver.Args(ev.Flags(src)))
+			cmdList = append(cmdList, item)
+			owners = append(owners, i)
+		}
 
-			// 添加默认的MSVC宏定义
-			defaultDefs := []string{
-				"WIN32",    // Windows平台
-				"_WINDOWS", // Windows应用程序
-				"_MBCS",    // 多字节字符集
-			}
-			// 根据配置添加特定宏
-			if strings.Contains(strings.ToLower(conf), "debug") {
-				defaultDefs = append(defaultDefs, "_DEBUG", "DEBUG") // Debug配置
-			} else {
-				defaultDefs = append(defaultDefs, "NDEBUG") // Release配置
-			}
-			if strings.Contains(strings.ToLower(conf), "win32") {
-				defaultDefs = append(defaultDefs, "_WIN32") // 32位平台
-			} else if strings.Contains(strings.ToLower(conf), "x64") {
-				defaultDefs = append(defaultDefs, "_WIN64") // 64位平台
-			}
+	}
+	return sln.selectSharedEntries(cmdList, owners), nil
+}
 
-			// 合并默认宏定义
-			if len(defaultDefs) > 0 {
-				allDefs = MergeSemicolonSeparatedLists(allDefs, strings.Join(defaultDefs, ";"))
-			}
+// selectSharedEntries 按Options.Shared处理出现在多个项目中的同一个源文件，owners为每个条目所属项目的下标
+//
+// 按项目下标而不是项目名区分项目，不同目录下的同名项目不会被当作同一个项目。
+func (sln *Sln) selectSharedEntries(cmdList []CompileCommand, owners []int) []CompileCommand {
+	policy := sln.Options.Shared
+	if policy == "" || policy == SharedAll {
+		return cmdList
+	}
+
+	// 每个文件选中的项目，默认为第一个包含它的项目
+	chosen := map[string]int{}
+	preferred := policy.preferredProject()
+	for i, item := range cmdList {
+		key := pathKey(commandFilePath(item))
+		if _, ok := chosen[key]; !ok || (preferred != "" && sln.isPreferred(&sln.ProjectList[owners[i]], preferred)) {
+			chosen[key] = owners[i]
+		}
+	}
 
-			// 合并额外编译选项
-			allOpts := MergeSemicolonSeparatedLists(additionalOpts, extraOpt)
+	var list []CompileCommand
+	for i, item := range cmdList {
+		if chosen[pathKey(commandFilePath(item))] == owners[i] {
+			list = append(list, item)
+		}
+	}
+	return list
+}
 
-			// 处理Conan等包管理器路径
-			allIncludeDirs = ProcessConanPaths(allIncludeDirs)
+// isPreferred 判断pro是否为prefer:指定的项目，preferred可以是项目名，也可以是项目文件路径，
+// 相对路径基于解决方案目录
+func (sln *Sln) isPreferred(pro *Project, preferred string) bool {
+	if strings.EqualFold(pro.Name(), preferred) {
+		return true
+	}
+	return pathKey(absItemPath(sln.SolutionDir, preferred)) == pathKey(pro.ProjectPath)
+}
 
-			// 清理和格式化参数
-			allDefs = RemoveBadDefinition(allDefs)
-			allDefs = preappend(allDefs, "-D")
+// commandFilePath 返回条目中源文件的绝对路径
+func commandFilePath(item CompileCommand) string {
+	return absItemPath(item.Dir, item.File)
+}
 
-			allIncludeDirs = RemoveBadInclude(allIncludeDirs)
-			allIncludeDirs = preappend(allIncludeDirs, "-I")
+// systemIncludeDirs 返回MSVC标准库和Windows SDK的头文件目录（基于MSVC标准路径），
+// 未设置VSINSTALLDIR等环境变量时返回VS2022的默认安装路径，第二个返回值为true
+func systemIncludeDirs() ([]string, bool) {
+	var dirs []string
+	// 检查是否有Visual Studio环境变量
+	if vsInstallDir := os.Getenv("VSINSTALLDIR"); vsInstallDir != "" {
+		platformToolset := "v143" // 默认使用VS 2022的工具集
+		// 尝试从环境变量获取工具集版本
+		if toolset := os.Getenv("PlatformToolsetVersion"); toolset != "" {
+			platformToolset = toolset
+		}
 
-			// 处理额外编译选项
-			allOpts = strings.TrimSpace(allOpts)
-			// 移除%(AdditionalOptions)宏
-			allOpts = RemoveBadOptions(allOpts)
+		// 添加MSVC标准库include目录
+		dirs = append(dirs, filepath.Join(vsInstallDir, "VC", "Tools", "MSVC", platformToolset, "include"))
 
-			// 构建完整的编译命令
-			var cmdParts []string
-			cmdParts = append(cmdParts, "clang-cl.exe")
-			if strings.TrimSpace(allDefs) != "" {
-				cmdParts = append(cmdParts, strings.TrimSpace(allDefs))
-			}
-			if strings.TrimSpace(allIncludeDirs) != "" {
-				cmdParts = append(cmdParts, strings.TrimSpace(allIncludeDirs))
+		// 添加Windows SDK include目录
+		if windowsSdkDir := os.Getenv("WindowsSdkDir"); windowsSdkDir != "" {
+			if windowsSdkVersion := os.Getenv("WindowsSdkVersion"); windowsSdkVersion != "" {
+				dirs = append(dirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "um"))
+				dirs = append(dirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "shared"))
+				dirs = append(dirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "winrt"))
+				dirs = append(dirs, filepath.Join(windowsSdkDir, "Include", windowsSdkVersion, "cppwinrt"))
 			}
-			if allOpts != "" {
-				cmdParts = append(cmdParts, allOpts)
-			}
-			cmdParts = append(cmdParts, "-c", f)
-
-			item.Cmd = strings.Join(cmdParts, " ")
-			cmdList = append(cmdList, item)
 		}
-
 	}
-	return cmdList, nil
-}
-
-func preappend(sepedString string, append string) string {
-	defList := strings.Split(sepedString, ";")
-	var output string
 
-	for _, v := range defList {
-		v = append + v + " "
-		output += v
+	if len(dirs) > 0 {
+		return dirs, false
 	}
-	return output
-}
\ No newline at end of file
+
+	// 如果没有环境变量，使用VS 2022默认安装路径
+	dirs = append(dirs, "C:\\Program Files\\Microsoft Visual Studio\\2022\\Community\\VC\\Tools\\MSVC\\14.39.33519\\include")
+	dirs = append(dirs, "C:\\Program Files (x86)\\Windows Kits\\10\\Include\\10.0.22621.0\\um")
+	dirs = append(dirs, "C:\\Program Files (x86)\\Windows Kits\\10\\Include\\10.0.22621.0\\shared")
+	dirs = append(dirs, "C:\\Program Files (x86)\\Windows Kits\\10\\Include\\10.0.22621.0\\winrt")
+	return dirs, true
+}
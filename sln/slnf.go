@@ -0,0 +1,89 @@
+package sln
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SolutionFilter 解决方案筛选器(.slnf)，只加载解决方案中列出的项目
+type SolutionFilter struct {
+	Path string
+	// 所引用的解决方案的绝对路径
+	SolutionPath string
+	// 相对解决方案目录的项目路径，使用文件中的原始写法
+	Projects []string
+}
+
+// .slnf文件的JSON结构
+type slnfDocument struct {
+	Solution struct {
+		Path     string   `json:"path"`
+		Projects []string `json:"projects"`
+	} `json:"solution"`
+}
+
+// ParseSolutionFilter 解析.slnf文件，解决方案路径相对于.slnf所在目录
+func ParseSolutionFilter(path string) (*SolutionFilter, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Visual Studio保存的文件可能带有UTF-8 BOM
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var doc slnfDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if strings.TrimSpace(doc.Solution.Path) == "" {
+		return nil, fmt.Errorf("%s: solution path is missing", path)
+	}
+
+	slnPath := nativePath(doc.Solution.Path)
+	if !filepath.IsAbs(slnPath) {
+		slnPath = filepath.Join(filepath.Dir(path), slnPath)
+	}
+	return &SolutionFilter{
+		Path:         path,
+		SolutionPath: filepath.Clean(slnPath),
+		Projects:     doc.Solution.Projects,
+	}, nil
+}
+
+// Apply 返回只保留筛选器中列出的项目的解决方案，解决方案文件夹不受影响
+//
+// 筛选器中列出但解决方案中不存在的项目会输出警告。
+func (f *SolutionFilter) Apply(sf *SolutionFile) *SolutionFile {
+	listed := map[string]bool{}
+	for _, p := range f.Projects {
+		listed[slnfProjectKey(p)] = false
+	}
+
+	filtered := *sf
+	filtered.Projects = nil
+	for _, p := range sf.Projects {
+		key := slnfProjectKey(p.Path)
+		if _, ok := listed[key]; ok || p.IsFolder() {
+			listed[key] = true
+			filtered.Projects = append(filtered.Projects, p)
+		}
+	}
+
+	for _, p := range f.Projects {
+		if !listed[slnfProjectKey(p)] {
+			fmt.Fprintf(os.Stderr, "Warning: %s: project %s is not in solution %s\n", f.Path, p, sf.Path)
+		}
+	}
+	return &filtered
+}
+
+// slnfProjectKey 统一分隔符和大小写，用于比较.slnf和解决方案中的项目路径
+func slnfProjectKey(path string) string {
+	path = strings.Replace(strings.TrimSpace(path), "/", "\\", -1)
+	return strings.ToLower(strings.TrimPrefix(path, ".\\"))
+}
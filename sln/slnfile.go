@@ -0,0 +1,199 @@
+package sln
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 解决方案文件夹的项目类型GUID
+const solutionFolderTypeGUID = "{2150E333-8FDC-42A3-9474-1A3956D46DE8}"
+
+// SolutionFile 解决方案文件解析后的结构化内容
+type SolutionFile struct {
+	Path string
+	// 解决方案配置，格式为Configuration|Platform
+	Configurations []string
+	Projects       []SolutionProject
+}
+
+// SolutionProject 解决方案中的一个项目
+type SolutionProject struct {
+	TypeGUID string
+	Name     string
+	// 相对解决方案目录的项目路径，使用文件中的原始写法
+	Path string
+	GUID string
+	// 所在的解决方案文件夹，格式为/A/B/，位于根目录时为空
+	Folder string
+	// 解决方案配置到项目配置的映射（ActiveCfg），键为小写的解决方案配置
+	activeConfigs map[string]string
+	// 在对应的解决方案配置下是否参与生成（Build.0），键为小写的解决方案配置
+	buildConfigs map[string]bool
+	// 解决方案中声明的生成依赖，.sln中为项目GUID，.slnx中为相对解决方案目录的项目路径
+	Dependencies []string
+}
+
+var slnProjectLine = regexp.MustCompile(`^Project\("(\{[^}]+\})"\)\s*=\s*"([^"]*)"\s*,\s*"([^"]*)"\s*,\s*"(\{[^}]+\})"`)
+
+// ParseSolutionFile 解析.sln文件中的项目、解决方案配置以及解决方案配置到项目配置的映射
+//
+// 格式不正确的Project行输出警告并跳过，该Project块中的依赖一并忽略。
+func ParseSolutionFile(path string) (*SolutionFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sf := &SolutionFile{Path: path}
+	index := map[string]int{}
+	parents := map[string]string{}
+	section := ""
+	// 当前Project块的Project行格式不正确
+	malformed := false
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Project("):
+			m := slnProjectLine.FindStringSubmatch(line)
+			malformed = m == nil
+			if malformed {
+				fmt.Fprintf(os.Stderr, "Warning: %s: malformed project line, skipped: %s\n", path, line)
+				continue
+			}
+			p := SolutionProject{
+				TypeGUID:      strings.ToUpper(m[1]),
+				Name:          m[2],
+				Path:          m[3],
+				GUID:          strings.ToUpper(m[4]),
+				activeConfigs: map[string]string{},
+				buildConfigs:  map[string]bool{},
+			}
+			index[p.GUID] = len(sf.Projects)
+			sf.Projects = append(sf.Projects, p)
+		case strings.HasPrefix(line, "GlobalSection("), strings.HasPrefix(line, "ProjectSection("):
+			begin := strings.Index(line, "(")
+			end := strings.Index(line, ")")
+			if end > begin {
+				section = line[begin+1 : end]
+			}
+		case line == "EndGlobalSection", line == "EndProjectSection":
+			section = ""
+		case section == "ProjectDependencies":
+			// 位于Project块内：{依赖项目GUID} = {依赖项目GUID}
+			if key, _, ok := splitSlnAssignment(line); ok && !malformed && len(sf.Projects) > 0 {
+				p := &sf.Projects[len(sf.Projects)-1]
+				p.Dependencies = append(p.Dependencies, strings.ToUpper(key))
+			}
+		case section == "NestedProjects":
+			// {子项目GUID} = {父文件夹GUID}
+			if key, value, ok := splitSlnAssignment(line); ok {
+				parents[strings.ToUpper(key)] = strings.ToUpper(value)
+			}
+		case section == "SolutionConfigurationPlatforms":
+			if key, _, ok := splitSlnAssignment(line); ok {
+				sf.Configurations = append(sf.Configurations, key)
+			}
+		case section == "ProjectConfigurationPlatforms":
+			key, value, ok := splitSlnAssignment(line)
+			if !ok {
+				continue
+			}
+			// {GUID}.Debug|x64.ActiveCfg = Debug|x64
+			dot := strings.Index(key, "}.")
+			if dot < 0 {
+				continue
+			}
+			i, found := index[strings.ToUpper(key[:dot+1])]
+			if !found {
+				continue
+			}
+			p := &sf.Projects[i]
+			rest := key[dot+2:]
+			switch {
+			case strings.HasSuffix(rest, ".ActiveCfg"):
+				p.activeConfigs[strings.ToLower(strings.TrimSuffix(rest, ".ActiveCfg"))] = value
+			case strings.HasSuffix(rest, ".Build.0"):
+				p.buildConfigs[strings.ToLower(strings.TrimSuffix(rest, ".Build.0"))] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sf.Projects {
+		sf.Projects[i].Folder = slnFolderPath(sf, index, parents, parents[sf.Projects[i].GUID])
+	}
+	return sf, nil
+}
+
+// slnFolderPath 根据NestedProjects计算文件夹guid的完整路径
+func slnFolderPath(sf *SolutionFile, index map[string]int, parents map[string]string, guid string) string {
+	path := ""
+	// 限制层数，防止NestedProjects中的循环引用
+	for depth := 0; guid != "" && depth < len(sf.Projects); depth++ {
+		i, ok := index[guid]
+		if !ok {
+			break
+		}
+		path = sf.Projects[i].Name + "/" + path
+		guid = parents[guid]
+	}
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// splitSlnAssignment 拆分"key = value"形式的行
+func splitSlnAssignment(line string) (string, string, bool) {
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]), true
+}
+
+// HasConfiguration 判断解决方案是否定义了conf配置，不区分大小写
+func (sf *SolutionFile) HasConfiguration(conf string) bool {
+	for _, v := range sf.Configurations {
+		if strings.EqualFold(v, conf) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFolder 判断是否为解决方案文件夹
+func (p *SolutionProject) IsFolder() bool {
+	return p.TypeGUID == solutionFolderTypeGUID
+}
+
+// IsVcxproj 判断是否为VC++项目
+func (p *SolutionProject) IsVcxproj() bool {
+	return strings.EqualFold(filepath.Ext(p.Path), ".vcxproj")
+}
+
+// IsVcproj 判断是否为VS2005/2008的VC++项目
+func (p *SolutionProject) IsVcproj() bool {
+	return strings.EqualFold(filepath.Ext(p.Path), ".vcproj")
+}
+
+// ProjectConfiguration 返回解决方案配置conf映射到的项目配置以及该项目是否参与生成，
+// 解决方案中没有该项目的映射时第三个返回值为false
+func (p *SolutionProject) ProjectConfiguration(conf string) (string, bool, bool) {
+	key := strings.ToLower(conf)
+	mapped, ok := p.activeConfigs[key]
+	if !ok {
+		return "", false, false
+	}
+	return mapped, p.buildConfigs[key], true
+}
@@ -0,0 +1,52 @@
+package sln
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSolutionFileMalformedProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slnfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := `Microsoft Visual Studio Solution File, Format Version 12.00
+Project("{8BC9CEB8-8B4A-11D0-8D11-00A0C91BC942}") = "app", "app\app.vcxproj", "{11111111-1111-1111-1111-111111111111}"
+EndProject
+Project("{8BC9CEB8-8B4A-11D0-8D11-00A0C91BC942}") = "broken", "broken.vcxproj"
+	ProjectSection(ProjectDependencies) = postProject
+		{33333333-3333-3333-3333-333333333333} = {33333333-3333-3333-3333-333333333333}
+	EndProjectSection
+EndProject
+Project("{8BC9CEB8-8B4A-11D0-8D11-00A0C91BC942}") = "lib", "lib\lib.vcxproj", "{22222222-2222-2222-2222-222222222222}"
+EndProject
+Global
+	GlobalSection(SolutionConfigurationPlatforms) = preSolution
+		Debug|x64 = Debug|x64
+	EndGlobalSection
+EndGlobal
+`
+	path := filepath.Join(dir, "test.sln")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sf, err := ParseSolutionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sf.Projects) != 2 || sf.Projects[0].Name != "app" || sf.Projects[1].Name != "lib" {
+		t.Fatalf("Projects = %+v, want app and lib", sf.Projects)
+	}
+	// 格式不正确的Project块中的依赖不能计入前一个项目
+	if len(sf.Projects[0].Dependencies) != 0 {
+		t.Errorf("app.Dependencies = %q, want none", sf.Projects[0].Dependencies)
+	}
+	if len(sf.Configurations) != 1 || sf.Configurations[0] != "Debug|x64" {
+		t.Errorf("Configurations = %q, want [Debug|x64]", sf.Configurations)
+	}
+}
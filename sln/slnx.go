@@ -0,0 +1,189 @@
+package sln
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// .slnx文件的XML结构
+type slnxSolution struct {
+	XMLName        xml.Name           `xml:"Solution"`
+	Configurations slnxConfigurations `xml:"Configurations"`
+	Folders        []slnxFolder       `xml:"Folder"`
+	Projects       []slnxProject      `xml:"Project"`
+}
+
+type slnxConfigurations struct {
+	BuildTypes []slnxName `xml:"BuildType"`
+	Platforms  []slnxName `xml:"Platform"`
+}
+
+type slnxName struct {
+	Name string `xml:"Name,attr"`
+}
+
+// slnx中的文件夹是扁平的，Name为完整路径，例如/Libraries/Core/
+type slnxFolder struct {
+	Name     string        `xml:"Name,attr"`
+	Projects []slnxProject `xml:"Project"`
+}
+
+type slnxProject struct {
+	Path        string `xml:"Path,attr"`
+	Type        string `xml:"Type,attr"`
+	ID          string `xml:"Id,attr"`
+	DisplayName string `xml:"DisplayName,attr"`
+	// 解决方案配置到项目配置的映射规则，后出现的规则优先
+	BuildTypes []slnxMapping `xml:"BuildType"`
+	Platforms  []slnxMapping `xml:"Platform"`
+	Builds     []slnxMapping `xml:"Build"`
+	// 生成依赖，Project为相对解决方案目录的项目路径
+	BuildDependencies []slnxMapping `xml:"BuildDependency"`
+}
+
+// slnxMapping 映射规则，Solution为"BuildType|Platform"形式的模式，*匹配任意值
+type slnxMapping struct {
+	Solution string `xml:"Solution,attr"`
+	Project  string `xml:"Project,attr"`
+}
+
+// 没有Configurations元素时Visual Studio使用的默认配置
+var (
+	slnxDefaultBuildTypes = []string{"Debug", "Release"}
+	slnxDefaultPlatforms  = []string{"Any CPU"}
+)
+
+// ParseSlnx 解析.slnx格式的解决方案，返回与.sln相同的结构
+func ParseSlnx(filePath string) (*SolutionFile, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var doc slnxSolution
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+
+	buildTypes := slnxNames(doc.Configurations.BuildTypes, slnxDefaultBuildTypes)
+	platforms := slnxNames(doc.Configurations.Platforms, slnxDefaultPlatforms)
+
+	sf := &SolutionFile{Path: filePath}
+	for _, bt := range buildTypes {
+		for _, pf := range platforms {
+			sf.Configurations = append(sf.Configurations, bt+"|"+pf)
+		}
+	}
+
+	addProject := func(folder string, p slnxProject) {
+		sf.Projects = append(sf.Projects, newSlnxProject(folder, p, buildTypes, platforms))
+	}
+	for _, p := range doc.Projects {
+		addProject("", p)
+	}
+	for _, f := range doc.Folders {
+		name := "/" + strings.Trim(f.Name, "/") + "/"
+		parent, base := path.Split(strings.TrimSuffix(name, "/"))
+		if parent == "/" {
+			parent = ""
+		}
+		// 与.sln一致，文件夹本身也作为一个条目
+		sf.Projects = append(sf.Projects, SolutionProject{
+			TypeGUID: solutionFolderTypeGUID,
+			Name:     base,
+			Path:     base,
+			Folder:   parent,
+		})
+		for _, p := range f.Projects {
+			addProject(name, p)
+		}
+	}
+	return sf, nil
+}
+
+func slnxNames(names []slnxName, defaults []string) []string {
+	if len(names) == 0 {
+		return defaults
+	}
+	var list []string
+	for _, v := range names {
+		list = append(list, v.Name)
+	}
+	return list
+}
+
+// newSlnxProject 按映射规则计算项目在每个解决方案配置下的项目配置和是否参与生成
+func newSlnxProject(folder string, p slnxProject, buildTypes, platforms []string) SolutionProject {
+	name := p.DisplayName
+	if name == "" {
+		base := path.Base(strings.Replace(p.Path, "\\", "/", -1))
+		name = strings.TrimSuffix(base, path.Ext(base))
+	}
+	sp := SolutionProject{
+		TypeGUID:      strings.ToUpper(p.Type),
+		Name:          name,
+		Path:          p.Path,
+		GUID:          strings.ToUpper(p.ID),
+		Folder:        folder,
+		activeConfigs: map[string]string{},
+		buildConfigs:  map[string]bool{},
+	}
+	if sp.GUID != "" && !strings.HasPrefix(sp.GUID, "{") {
+		sp.GUID = "{" + sp.GUID + "}"
+	}
+	for _, dep := range p.BuildDependencies {
+		sp.Dependencies = append(sp.Dependencies, dep.Project)
+	}
+
+	for _, bt := range buildTypes {
+		for _, pf := range platforms {
+			projectBuildType := bt
+			projectPlatform := pf
+			// VC++项目中x86平台名为Win32
+			if (sp.IsVcxproj() || sp.IsVcproj()) && strings.EqualFold(pf, "x86") {
+				projectPlatform = "Win32"
+			}
+			build := true
+
+			for _, m := range p.BuildTypes {
+				if matchSlnxPattern(m.Solution, bt, pf) {
+					projectBuildType = m.Project
+				}
+			}
+			for _, m := range p.Platforms {
+				if matchSlnxPattern(m.Solution, bt, pf) {
+					projectPlatform = m.Project
+				}
+			}
+			for _, m := range p.Builds {
+				if matchSlnxPattern(m.Solution, bt, pf) {
+					build = !strings.EqualFold(strings.TrimSpace(m.Project), "false")
+				}
+			}
+
+			key := strings.ToLower(bt + "|" + pf)
+			sp.activeConfigs[key] = projectBuildType + "|" + projectPlatform
+			sp.buildConfigs[key] = build
+		}
+	}
+	return sp
+}
+
+// matchSlnxPattern 判断"BuildType|Platform"模式是否匹配，省略的部分和*匹配任意值
+func matchSlnxPattern(pattern, buildType, platform string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return true
+	}
+	parts := strings.SplitN(pattern, "|", 2)
+	match := func(p, v string) bool {
+		p = strings.TrimSpace(p)
+		return p == "" || p == "*" || strings.EqualFold(p, v)
+	}
+	if !match(parts[0], buildType) {
+		return false
+	}
+	return len(parts) == 1 || match(parts[1], platform)
+}
@@ -0,0 +1,359 @@
+package sln
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// VS2005/2008的.vcproj文件结构
+type vcprojFile struct {
+	XMLName        xml.Name              `xml:"VisualStudioProject"`
+	Configurations []vcprojConfiguration `xml:"Configurations>Configuration"`
+	Files          vcprojFilter          `xml:"Files"`
+}
+
+type vcprojConfiguration struct {
+	// 格式为Configuration|Platform
+	Name              string       `xml:"Name,attr"`
+	ConfigurationType string       `xml:"ConfigurationType,attr"`
+	CharacterSet      string       `xml:"CharacterSet,attr"`
+	UseOfMFC          string       `xml:"UseOfMFC,attr"`
+	UseOfATL          string       `xml:"UseOfATL,attr"`
+	Tools             []vcprojTool `xml:"Tool"`
+}
+
+// vcprojTool 工具的设置，全部以属性的形式保存
+type vcprojTool struct {
+	Name  string     `xml:"Name,attr"`
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+// vcprojFilter Files和Filter元素，Filter可以嵌套
+type vcprojFilter struct {
+	Filters []vcprojFilter    `xml:"Filter"`
+	Files   []vcprojFileEntry `xml:"File"`
+}
+
+type vcprojFileEntry struct {
+	RelativePath       string                    `xml:"RelativePath,attr"`
+	FileConfigurations []vcprojFileConfiguration `xml:"FileConfiguration"`
+}
+
+// vcprojFileConfiguration 单个文件在某个配置下的设置
+type vcprojFileConfiguration struct {
+	Name              string       `xml:"Name,attr"`
+	ExcludedFromBuild string       `xml:"ExcludedFromBuild,attr"`
+	Tools             []vcprojTool `xml:"Tool"`
+}
+
+// 编译器和链接器工具的名称
+const (
+	vcprojCompilerTool = "VCCLCompilerTool"
+	vcprojLinkerTool   = "VCLinkerTool"
+)
+
+// vcprojToolAttributes VCCLCompilerTool的属性到ClCompile元数据的转换，键为属性名
+var vcprojToolAttributes = map[string]func(string) string{
+	"AdditionalIncludeDirectories": vcprojList,
+	"PreprocessorDefinitions":      vcprojList,
+	"AdditionalUsingDirectories":   vcprojList,
+	"ForcedIncludeFiles":           vcprojList,
+	// 命令行文本，选项之间以空格分隔，保持原样
+	"AdditionalOptions": strings.TrimSpace,
+	"WarningLevel":      vcprojEnum("TurnOffAllWarnings", "Level1", "Level2", "Level3", "Level4"),
+	"Optimization":      vcprojEnum("Disabled", "MinSpace", "MaxSpeed", "Full"),
+	"RuntimeLibrary": vcprojEnum("MultiThreaded", "MultiThreadedDebug",
+		"MultiThreadedDLL", "MultiThreadedDebugDLL"),
+	"UsePrecompiledHeader":      vcprojEnum("NotUsing", "Create", "Use"),
+	"PrecompiledHeaderThrough":  strings.TrimSpace,
+	"PrecompiledHeaderFile":     strings.TrimSpace,
+	"ExceptionHandling":         vcprojEnum("false", "Sync", "Async"),
+	"RuntimeTypeInfo":           strings.TrimSpace,
+	"StructMemberAlignment":     vcprojEnum("Default", "1Byte", "2Bytes", "4Bytes", "8Bytes", "16Bytes"),
+	"TreatWChar_tAsBuiltInType": strings.TrimSpace,
+	"WarnAsError":               strings.TrimSpace,
+	"DisableSpecificWarnings":   vcprojList,
+}
+
+// vcprojLinkerAttributes VCLinkerTool的属性到Link元数据的转换，只包括影响默认宏定义的SubSystem
+var vcprojLinkerAttributes = map[string]func(string) string{
+	"SubSystem": vcprojEnum("NotSet", "Console", "Windows", "Native", "EFI Application",
+		"EFI Boot Service Driver", "EFI ROM", "EFI Runtime", "WindowsCE"),
+}
+
+// vcprojMetadataNames 名称与ClCompile元数据不同的VCCLCompilerTool属性，键为属性名
+var vcprojMetadataNames = map[string]string{
+	"UsePrecompiledHeader":     "PrecompiledHeader",
+	"PrecompiledHeaderThrough": "PrecompiledHeaderFile",
+	// .vcproj中的PrecompiledHeaderFile是.pch文件的路径
+	"PrecompiledHeaderFile": "PrecompiledHeaderOutputFile",
+	"WarnAsError":           "TreatWarningAsError",
+}
+
+// vcproj中枚举属性的数值到vcxproj名称的转换
+var (
+	vcprojConfigurationTypes = vcprojEnum("", "Application", "DynamicLibrary", "", "StaticLibrary",
+		"", "", "", "", "", "Utility")
+	vcprojCharacterSets = vcprojEnum("NotSet", "Unicode", "MultiByte")
+	// UseOfMFC和UseOfATL
+	vcprojLibraryUses = vcprojEnum("false", "Static", "Dynamic")
+)
+
+var (
+	vcprojInherit   = regexp.MustCompile(`(?i)\$\(\s*Inherit\s*\)`)
+	vcprojNoInherit = regexp.MustCompile(`(?i)\$\(\s*NoInherit\s*\)`)
+)
+
+// parseVcprojFile 解析.vcproj文件并转换为与.vcxproj相同的结构
+//
+// 每个Configuration转换为带条件的PropertyGroup和ItemDefinitionGroup，
+// Files中的C/C++源文件转换为ClCompile项，FileConfiguration转换为带条件的项元数据，
+// 因此后续的求值和编译命令生成与.vcxproj完全相同。
+func parseVcprojFile(path string) (*Project, error) {
+	var pro Project
+	var err error
+
+	pro.ProjectPath, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	pro.ProjectDir = filepath.Dir(pro.ProjectPath)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc vcprojFile
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = vcprojCharsetReader
+	if err := d.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", pro.ProjectPath, err)
+	}
+
+	var configs ItemGroup
+	configs.Label = "ProjectConfigurations"
+	for _, c := range doc.Configurations {
+		vlist := strings.SplitN(c.Name, "|", 2)
+		pc := newItem("ProjectConfiguration", c.Name)
+		pc.Metadata = append(pc.Metadata, newMetadata("Configuration", vlist[0], ""))
+		if len(vlist) == 2 {
+			pc.Metadata = append(pc.Metadata, newMetadata("Platform", vlist[1], ""))
+		}
+		configs.Items = append(configs.Items, pc)
+	}
+	pro.addItemGroup(configs)
+
+	for _, c := range doc.Configurations {
+		cond := vcprojCondition(c.Name)
+		group := PropertyGroup{Condition: cond, Label: "Configuration"}
+		if v := vcprojConfigurationTypes(c.ConfigurationType); v != "" {
+			group.Properties = append(group.Properties, newProperty("ConfigurationType", v))
+		}
+		if v := vcprojCharacterSets(c.CharacterSet); v != "" {
+			group.Properties = append(group.Properties, newProperty("CharacterSet", v))
+		}
+		if v := vcprojLibraryUses(c.UseOfMFC); v != "" {
+			group.Properties = append(group.Properties, newProperty("UseOfMfc", v))
+		}
+		if v := vcprojLibraryUses(c.UseOfATL); v != "" {
+			group.Properties = append(group.Properties, newProperty("UseOfAtl", v))
+		}
+		pro.addPropertyGroup(group)
+
+		defs := []ItemDefinition{{XMLName: xml.Name{Local: "ClCompile"}, Metadata: vcprojCompilerMetadata(c.Tools)}}
+		if md := vcprojToolMetadata(c.Tools, vcprojLinkerTool, vcprojLinkerAttributes); len(md) > 0 {
+			defs = append(defs, ItemDefinition{XMLName: xml.Name{Local: "Link"}, Metadata: md})
+		}
+		pro.addItemDefinitionGroup(ItemDefinitionGroup{Condition: cond, Definitions: defs})
+	}
+
+	var sources ItemGroup
+	doc.Files.collectSources(&sources)
+	pro.addItemGroup(sources)
+	return &pro, nil
+}
+
+// collectSources 递归收集Filter中的C/C++源文件
+func (f *vcprojFilter) collectSources(group *ItemGroup) {
+	for _, file := range f.Files {
+		if !isCppSource(file.RelativePath) {
+			continue
+		}
+		item := newItem("ClCompile", file.RelativePath)
+		for _, fc := range file.FileConfigurations {
+			cond := vcprojCondition(fc.Name)
+			if strings.TrimSpace(fc.ExcludedFromBuild) != "" {
+				item.Metadata = append(item.Metadata, newMetadata("ExcludedFromBuild", fc.ExcludedFromBuild, cond))
+			}
+			for _, m := range vcprojCompilerMetadata(fc.Tools) {
+				m.Condition = cond
+				item.Metadata = append(item.Metadata, m)
+			}
+		}
+		group.Items = append(group.Items, item)
+	}
+	for i := range f.Filters {
+		f.Filters[i].collectSources(group)
+	}
+}
+
+// vcprojCompilerMetadata 把VCCLCompilerTool的属性转换为ClCompile元数据
+func vcprojCompilerMetadata(tools []vcprojTool) []Metadata {
+	return vcprojToolMetadata(tools, vcprojCompilerTool, vcprojToolAttributes)
+}
+
+// vcprojToolMetadata 按attributes把名为toolName的工具的属性转换为元数据
+//
+// 属性名按vcprojMetadataNames转换为元数据名；$(Inherit)转换为%(Name)，继承属性表或项目级的设置；$(NoInherit)直接去掉。
+func vcprojToolMetadata(tools []vcprojTool, toolName string, attributes map[string]func(string) string) []Metadata {
+	var list []Metadata
+	for _, tool := range tools {
+		if tool.Name != toolName {
+			continue
+		}
+		for _, attr := range tool.Attrs {
+			convert, ok := attributes[attr.Name.Local]
+			if !ok {
+				continue
+			}
+			name := attr.Name.Local
+			if v, ok := vcprojMetadataNames[name]; ok {
+				name = v
+			}
+			value := vcprojInherit.ReplaceAllLiteralString(attr.Value, "%("+name+")")
+			value = vcprojNoInherit.ReplaceAllLiteralString(value, "")
+			converted := convert(value)
+			if converted == "" && strings.TrimSpace(value) != "" {
+				// 无法转换的值（例如超出范围的枚举值）视为未设置，不覆盖继承的设置
+				continue
+			}
+			list = append(list, newMetadata(name, converted, ""))
+		}
+	}
+	return list
+}
+
+// vcprojList 把以逗号或分号分隔、可能带引号的列表转换为分号分隔的列表
+//
+// 只去掉包围整个列表项的引号，列表项中的引号（例如FOO=\"bar\"）保持原样。
+func vcprojList(s string) string {
+	var list []string
+	quoted := false
+	begin := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			if s[i] == '"' {
+				quoted = !quoted
+			}
+			if quoted || (s[i] != ',' && s[i] != ';') {
+				continue
+			}
+		}
+		v := strings.TrimSpace(s[begin:i])
+		if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			v = strings.TrimSpace(v[1 : len(v)-1])
+		}
+		if v != "" {
+			list = append(list, v)
+		}
+		begin = i + 1
+	}
+	return strings.Join(list, ";")
+}
+
+// vcprojEnum 返回把数值转换为对应名称的函数，非数值保持原样，超出范围的数值转换为空字符串，表示未设置
+func vcprojEnum(names ...string) func(string) string {
+	return func(s string) string {
+		s = strings.TrimSpace(s)
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return s
+		}
+		if n >= 0 && n < len(names) {
+			return names[n]
+		}
+		return ""
+	}
+}
+
+// vcprojCondition 返回只在conf配置下成立的条件
+func vcprojCondition(conf string) string {
+	return "'$(Configuration)|$(Platform)'=='" + conf + "'"
+}
+
+// vcprojCharsetReader 处理.vcproj常见的非UTF-8编码声明
+//
+// Windows-1252、ISO-8859-1、gb2312、GBK和GB18030（简体中文版VS2005/2008的默认编码）按对应的编码转换为UTF-8；其他编码的内容必须是合法的UTF-8（例如只包含ASCII字符），否则返回指明该编码的错误。
+func vcprojCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "windows-1252", "cp1252":
+		return transform.NewReader(input, charmap.Windows1252.NewDecoder()), nil
+	case "iso-8859-1", "latin1":
+		return transform.NewReader(input, charmap.ISO8859_1.NewDecoder()), nil
+	case "gb2312", "gbk", "cp936", "windows-936", "x-gbk":
+		return transform.NewReader(input, simplifiedchinese.GBK.NewDecoder()), nil
+	case "gb18030":
+		return transform.NewReader(input, simplifiedchinese.GB18030.NewDecoder()), nil
+	}
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("unsupported encoding %q, save the project as UTF-8", charset)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// isCppSource 判断文件是否为由编译器处理的C/C++源文件
+func isCppSource(path string) bool {
+	switch strings.ToLower(filepath.Ext(nativePath(path))) {
+	case ".c", ".cc", ".cpp", ".cxx", ".c++":
+		return true
+	}
+	return false
+}
+
+func newProperty(name, value string) Property {
+	return Property{XMLName: xml.Name{Local: name}, Value: value}
+}
+
+func newItem(itemType, include string) Item {
+	return Item{XMLName: xml.Name{Local: itemType}, Include: include}
+}
+
+func newMetadata(name, value, condition string) Metadata {
+	return Metadata{XMLName: xml.Name{Local: name}, Value: value, Condition: condition}
+}
+
+// isVcproj 判断项目是否来自.vcproj文件
+func (pro *Project) isVcproj() bool {
+	return strings.EqualFold(filepath.Ext(pro.ProjectPath), ".vcproj")
+}
+
+func (pro *Project) addPropertyGroup(v PropertyGroup) {
+	pro.elements = append(pro.elements, elementRef{elementPropertyGroup, len(pro.PropertyGroup)})
+	pro.PropertyGroup = append(pro.PropertyGroup, v)
+}
+
+func (pro *Project) addItemGroup(v ItemGroup) {
+	pro.elements = append(pro.elements, elementRef{elementItemGroup, len(pro.ItemGroup)})
+	pro.ItemGroup = append(pro.ItemGroup, v)
+}
+
+func (pro *Project) addItemDefinitionGroup(v ItemDefinitionGroup) {
+	pro.elements = append(pro.elements, elementRef{elementItemDefinitionGroup, len(pro.ItemDefinitionGroup)})
+	pro.ItemDefinitionGroup = append(pro.ItemDefinitionGroup, v)
+}
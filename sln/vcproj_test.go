@@ -0,0 +1,120 @@
+package sln
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVcprojList(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{`WIN32,_DEBUG;_CONSOLE`, "WIN32;_DEBUG;_CONSOLE"},
+		{`"..\inc";"C:\Program Files\x"`, `..\inc;C:\Program Files\x`},
+		{`"a,b";c`, "a,b;c"},
+		{`FOO=\"bar\";BAZ`, `FOO=\"bar\";BAZ`},
+		{` ; , `, ""},
+	}
+	for _, tt := range tests {
+		if got := vcprojList(tt.s); got != tt.want {
+			t.Errorf("vcprojList(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+// parseTestVcproj 在临时目录中写入.vcproj文件并在Debug|Win32配置下求值
+func parseTestVcproj(t *testing.T, content []byte) (*Evaluation, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "vcproj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.vcproj")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	pro, err := NewProject(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	ev, err := pro.Evaluate("Debug|Win32")
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return ev, func() { os.RemoveAll(dir) }
+}
+
+func TestVcprojCompilerSettings(t *testing.T) {
+	// 0x93和0x94是Windows-1252中的弯引号，0x80是欧元符号
+	content := []byte(`<?xml version="1.0" encoding="Windows-1252"?>
+<VisualStudioProject ProjectType="Visual C++" Version="9.00" Name="test">
+  <Configurations>
+    <Configuration Name="Debug|Win32" ConfigurationType="1">
+      <Tool Name="VCCLCompilerTool" PreprocessorDefinitions="WIN32;FOO=\&quot;bar\&quot;;NAME=` + "\x93\x80\x94" + `"
+        WarningLevel="3" RuntimeLibrary="3" />
+    </Configuration>
+  </Configurations>
+  <Files>
+    <File RelativePath=".\main.cpp">
+      <FileConfiguration Name="Debug|Win32">
+        <Tool Name="VCCLCompilerTool" WarningLevel="9" RuntimeLibrary="1" />
+      </FileConfiguration>
+    </File>
+  </Files>
+</VisualStudioProject>`)
+	ev, cleanup := parseTestVcproj(t, content)
+	defer cleanup()
+
+	items := ev.Items("ClCompile")
+	if len(items) != 1 {
+		t.Fatalf("got %d ClCompile items, want 1", len(items))
+	}
+	tests := []struct {
+		name, want string
+	}{
+		{"PreprocessorDefinitions", `WIN32;FOO=\"bar\";NAME=“€”`},
+		// 超出范围的枚举值不覆盖项目级的设置
+		{"WarningLevel", "Level3"},
+		{"RuntimeLibrary", "MultiThreadedDebug"},
+	}
+	for _, tt := range tests {
+		if got := items[0].Metadata.Get(tt.name); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVcprojSubSystem(t *testing.T) {
+	tests := []struct {
+		subSystem string
+		want      string
+		notWant   string
+	}{
+		{"2", "_WINDOWS", "_CONSOLE"},
+		{"1", "_CONSOLE", "_WINDOWS"},
+	}
+	for _, tt := range tests {
+		content := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<VisualStudioProject ProjectType="Visual C++" Version="9.00" Name="test">
+  <Configurations>
+    <Configuration Name="Debug|Win32" ConfigurationType="1">
+      <Tool Name="VCCLCompilerTool" />
+      <Tool Name="VCLinkerTool" SubSystem="` + tt.subSystem + `" />
+    </Configuration>
+  </Configurations>
+</VisualStudioProject>`)
+		ev, cleanup := parseTestVcproj(t, content)
+		defs := ev.defaultDefinitions()
+		cleanup()
+		joined := ";" + strings.Join(defs, ";") + ";"
+		if !strings.Contains(joined, ";"+tt.want+";") || strings.Contains(joined, ";"+tt.notWant+";") {
+			t.Errorf("SubSystem=%s: default definitions %q, want %s and not %s", tt.subSystem, defs, tt.want, tt.notWant)
+		}
+	}
+}